@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/results"
+	"github.com/charliekim2/songsleuths/utils"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusMethodNotAllowed
+	err := errors.New("Invalid request method")
+
+	if r.Method == http.MethodGet {
+		status, err = get(w, r)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+type Export struct {
+	ID          string             `json:"id"`
+	Name        string             `json:"name"`
+	Deadline    uint               `json:"deadline"`
+	Scoring     string             `json:"scoring"`
+	RankingMode string             `json:"ranking_mode"`
+	Submissions []Submission       `json:"submissions"`
+	Decoys      []Song             `json:"decoys,omitempty"` // Host-added songs that belong to no player
+	Placements  []Placement        `json:"placements"`
+	Guesses     []results.Score    `json:"guesses"`
+	Rankings    *results.Consensus `json:"rankings"`
+}
+
+type Submission struct {
+	PlayerID string `json:"player_id"`
+	Nickname string `json:"nickname"`
+	Songs    []Song `json:"songs"`
+}
+
+type Song struct {
+	ID      uint   `json:"id"`
+	Spotify string `json:"spotify"`
+	Name    string `json:"name"`
+}
+
+type Placement struct {
+	results.Placement
+	Nickname string `json:"nickname"`
+	Correct  *bool  `json:"correct,omitempty"` // Guess list placements only
+}
+
+// Exports a revealed game as JSON, or as CSV with ?format=csv
+func get(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	gid := strings.TrimPrefix(r.URL.Path, "/api/export/")
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	game, err := results.LoadGame(conn, gid)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	member, err := db.IsMember(conn, gid, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !member && game.HostID != uid {
+		return http.StatusForbidden, errors.New("join the game first")
+	}
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = lifecycle.Check(phase, lifecycle.Revealed, lifecycle.Archived); err != nil {
+		return http.StatusForbidden, errors.New("results are not revealed yet")
+	}
+
+	export, err := build(game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", game.ID))
+		w.WriteHeader(http.StatusOK)
+		writeCSV(w, export)
+		return http.StatusOK, nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.json\"", game.ID))
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(export)
+	return http.StatusOK, nil
+}
+
+func build(game *db.Game) (*Export, error) {
+	guesses, err := results.ScoreGuesses(game)
+	if err != nil {
+		return nil, err
+	}
+	rankings, err := results.ScoreSongs(game)
+	if err != nil {
+		return nil, err
+	}
+	placements, err := results.Placements(game)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &Export{
+		ID:          game.ID,
+		Name:        game.Name,
+		Deadline:    game.Deadline,
+		Scoring:     game.Scoring,
+		RankingMode: game.RankingMode,
+		Submissions: []Submission{},
+		Placements:  []Placement{},
+		Guesses:     guesses,
+		Rankings:    rankings,
+	}
+
+	nicknames := make(map[string]string)
+	for _, s := range game.Submissions {
+		if s.PlayerID == db.DecoyPlayer {
+			for _, song := range s.Songs {
+				export.Decoys = append(export.Decoys, Song{ID: song.ID, Spotify: song.Spotify, Name: song.Name})
+			}
+			continue
+		}
+		nicknames[s.PlayerID] = s.Nickname
+		sub := Submission{PlayerID: s.PlayerID, Nickname: s.Nickname, Songs: []Song{}}
+		for _, song := range s.Songs {
+			sub.Songs = append(sub.Songs, Song{ID: song.ID, Spotify: song.Spotify, Name: song.Name})
+		}
+		export.Submissions = append(export.Submissions, sub)
+	}
+	owners := results.OwnersOf(game)
+	for _, p := range placements {
+		placement := Placement{Placement: p, Nickname: nicknames[p.PlayerID]}
+		if p.List == "guess" {
+			correct := owners.Tiers[p.TierID] == owners.Songs[p.SongID]
+			placement.Correct = &correct
+		}
+		export.Placements = append(export.Placements, placement)
+	}
+	return export, nil
+}
+
+// writeCSV flattens the export into one table, one row per record. The type
+// column says whether a row is a submitted song, a decoy, a placement, a
+// guess score or a song's consensus result.
+func writeCSV(w http.ResponseWriter, export *Export) {
+	out := csv.NewWriter(w)
+	out.Write([]string{"type", "player_id", "nickname", "song_id", "song", "spotify", "tier", "correct", "points", "rank"})
+
+	songs := make(map[uint]Song)
+	for _, s := range export.Submissions {
+		for _, song := range s.Songs {
+			songs[song.ID] = song
+			out.Write([]string{"submission", s.PlayerID, s.Nickname, id(song.ID), song.Name, song.Spotify, "", "", "", ""})
+		}
+	}
+	for _, song := range export.Decoys {
+		songs[song.ID] = song
+		out.Write([]string{"decoy", "", "", id(song.ID), song.Name, song.Spotify, "", "", "", ""})
+	}
+	for _, p := range export.Placements {
+		correct := ""
+		if p.Correct != nil {
+			correct = strconv.FormatBool(*p.Correct)
+		}
+		song := songs[p.SongID]
+		out.Write([]string{p.List, p.PlayerID, p.Nickname, id(p.SongID), song.Name, song.Spotify, p.Tier, correct, "", ""})
+	}
+	for _, s := range export.Guesses {
+		out.Write([]string{"score", s.PlayerID, s.Nickname, "", "", "", "", strconv.Itoa(s.Correct), number(s.Points), strconv.Itoa(s.Rank)})
+	}
+	for _, s := range export.Rankings.Songs {
+		points := strconv.Itoa(s.Points)
+		if export.RankingMode == "pairwise" {
+			points = number(s.Strength)
+		}
+		out.Write([]string{"song", s.PlayerID, s.Nickname, id(s.SongID), s.Name, s.Spotify, "", "", points, strconv.Itoa(s.Rank)})
+	}
+	out.Flush()
+}
+
+func id(n uint) string {
+	return strconv.FormatUint(uint64(n), 10)
+}
+
+func number(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
@@ -1,37 +1,79 @@
 package handler
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"net/http"
+	"sort"
 
 	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/results"
+	"github.com/charliekim2/songsleuths/rules"
 	"github.com/charliekim2/songsleuths/utils"
 )
 
 type game struct {
-	ID       string `json:"id,omitempty"`
-	Name     string `json:"name"`
-	Deadline uint   `json:"deadline"`
-	NSongs   uint   `json:"n_songs"`
+	ID              string   `json:"id,omitempty"`
+	Name            string   `json:"name"`
+	Deadline        uint     `json:"deadline"`
+	RankingDeadline uint     `json:"ranking_deadline,omitempty"`
+	NSongs          uint     `json:"n_songs"`
+	MinSongs        uint     `json:"min_songs,omitempty"` // Defaults to n_songs
+	MinPlayers      uint     `json:"min_players,omitempty"`
+	MaxPlayers      uint     `json:"max_players,omitempty"`
+	Scoring         string   `json:"scoring,omitempty"`
+	RankingMode     string   `json:"ranking_mode,omitempty"`
+	InviteToken     string   `json:"invite_token,omitempty"`
+	Tiers           []string `json:"tiers,omitempty"` // Ranking tier names, best first
+	Prompt          string   `json:"prompt,omitempty"`
+	Rules           []rule   `json:"rules,omitempty"`
+	Teams           []string `json:"teams,omitempty"`  // Team names, players pick one when submitting
+	Rounds          []round  `json:"rounds,omitempty"` // Rounds after the first, which the fields above describe
+	Draft           bool     `json:"draft,omitempty"`  // Hold submissions until the host opens the game
 }
 
-type playlistRequest struct {
-	Name        string `json:"name"`
-	Public      bool   `json:"public"`
-	Description string `json:"description"`
+type round struct {
+	Prompt          string `json:"prompt,omitempty"`
+	Deadline        uint   `json:"deadline"`
+	RankingDeadline uint   `json:"ranking_deadline,omitempty"`
+	NSongs          uint   `json:"n_songs"`
+	MinSongs        uint   `json:"min_songs,omitempty"` // Defaults to n_songs
 }
 
-type playlistResponse struct {
-	ID string `json:"id"`
+type rule struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value,omitempty"`
+}
+
+type archivedGame struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	ArchivedAt int64  `json:"archived_at"`
+	PurgeAt    int64  `json:"purge_at"` // When the game can no longer be restored
+}
+
+type myGame struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Phase           string `json:"phase"`
+	Round           uint   `json:"round"`
+	NRounds         uint   `json:"n_rounds"`
+	Deadline        uint   `json:"deadline"`
+	RankingDeadline uint   `json:"ranking_deadline,omitempty"`
+	OwesSubmission  bool   `json:"owes_submission"`
+	OwesRanking     bool   `json:"owes_ranking"`
 }
 
 func Handler(w http.ResponseWriter, r *http.Request) {
 	status := http.StatusMethodNotAllowed
 	err := errors.New("Invalid request method")
 
-	if r.Method == http.MethodPost {
+	if r.Method == http.MethodGet && r.URL.Query().Get("archived") == "true" {
+		status, err = archived(w, r)
+	} else if r.Method == http.MethodGet {
+		status, err = get(w, r)
+	} else if r.Method == http.MethodPost {
 		status, err = post(w, r)
 	}
 
@@ -40,74 +82,241 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func post(w http.ResponseWriter, r *http.Request) (int, error) {
-	_, err := utils.Authenticate(r)
+// Lists the games the caller is a member of, newest first
+func get(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
 	if err != nil {
 		return http.StatusUnauthorized, err
 	}
 
-	var g game
-	err = json.NewDecoder(r.Body).Decode(&g)
+	conn, err := db.Connect()
 	if err != nil {
-		return http.StatusBadRequest, err
+		return http.StatusInternalServerError, err
+	}
+	player := &db.Player{}
+	err = conn.Preload("Games.Tierlists").First(player, "id = ?", uid).Error
+	if err != nil {
+		return http.StatusNotFound, err
 	}
 
-	conn, err := db.Connect()
+	gids := []string{}
+	for _, g := range player.Games {
+		gids = append(gids, g.ID)
+	}
+	submissions := []db.Submission{}
+	err = conn.Where("player_id = ? AND game_id IN ?", uid, gids).Find(&submissions).Error
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
-
-	// TODO: Move this logic to spotify utility
-	tok, err := utils.RefreshToken()
+	rankings := []db.Ranking{}
+	err = conn.Where("player_id = ? AND game_id IN ?", uid, gids).Find(&rankings).Error
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
 
-	playlist := playlistRequest{
-		Name:        g.Name,
-		Description: "Song Sleuths playlist for " + g.Name,
-		Public:      false,
+	games := []myGame{}
+	for _, g := range player.Games {
+		phase, err := lifecycle.Sync(conn, g)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+
+		// Only the current round's submission and rankings count
+		submitted := false
+		for _, s := range submissions {
+			submitted = submitted || (s.GameID == g.ID && s.Round == g.CurrentRound)
+		}
+		lists := make(map[uint]bool)
+		for _, list := range g.Tierlists {
+			lists[list.ID] = list.Round == g.CurrentRound
+		}
+		ranked, tierlists := 0, 0
+		for _, r := range rankings {
+			if r.GameID == g.ID && lists[r.TierlistID] {
+				ranked++
+			}
+		}
+		for _, current := range lists {
+			if current {
+				tierlists++
+			}
+		}
+
+		games = append(games, myGame{
+			ID:              g.ID,
+			Name:            g.Name,
+			Phase:           string(phase),
+			Round:           g.CurrentRound,
+			NRounds:         g.NRounds,
+			Deadline:        g.Deadline,
+			RankingDeadline: g.RankingDeadline,
+			OwesSubmission:  phase == lifecycle.Open && !submitted,
+			OwesRanking:     phase == lifecycle.Ranking && ranked < tierlists,
+		})
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].Deadline > games[j].Deadline })
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(games)
+	return http.StatusOK, nil
+}
+
+// Lists the games the caller archived as host, most recently archived first
+func archived(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
 	}
-	body, err := json.Marshal(&playlist)
+
+	conn, err := db.Connect()
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
-	req, err := http.NewRequest("POST", "https://api.spotify.com/v1/users/charliekim451/playlists", bytes.NewBuffer(body))
+	dbGames := []db.Game{}
+	err = conn.Unscoped().
+		Where("host_id = ? AND deleted_at IS NOT NULL", uid).
+		Order("deleted_at DESC").
+		Find(&dbGames).Error
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", "Bearer "+tok)
 
-	client := &http.Client{}
-	res, err := client.Do(req)
+	games := []archivedGame{}
+	for _, g := range dbGames {
+		games = append(games, archivedGame{
+			ID:         g.ID,
+			Name:       g.Name,
+			ArchivedAt: g.DeletedAt.Time.Unix(),
+			PurgeAt:    g.DeletedAt.Time.Add(db.Retention).Unix(),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(games)
+	return http.StatusOK, nil
+}
+
+func post(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return http.StatusUnauthorized, err
 	}
-	defer res.Body.Close()
 
-	if res.StatusCode != http.StatusCreated {
-		return res.StatusCode, errors.New("Spotify rejected playlist creation request")
+	var g game
+	err = json.NewDecoder(r.Body).Decode(&g)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if g.Scoring == "" {
+		g.Scoring = results.DefaultStrategy
+	}
+	if _, err = results.StrategyFor(g.Scoring); err != nil {
+		return http.StatusBadRequest, err
+	}
+	// Check tiers before the playlist is made so a bad request leaves nothing behind
+	if len(g.Tiers) > 0 {
+		if err = db.ValidateTiers(g.Tiers); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+	if g.MinSongs == 0 {
+		g.MinSongs = g.NSongs
 	}
-	playlistId := playlistResponse{}
-	err = json.NewDecoder(res.Body).Decode(&playlistId)
+	if err = db.ValidateSongRange(g.MinSongs, g.NSongs); err != nil {
+		return http.StatusBadRequest, err
+	}
+	rounds := []db.Round{{
+		Number:          1,
+		Prompt:          g.Prompt,
+		Deadline:        g.Deadline,
+		RankingDeadline: g.RankingDeadline,
+		NSongs:          g.NSongs,
+		MinSongs:        g.MinSongs,
+	}}
+	for _, r := range g.Rounds {
+		if r.MinSongs == 0 {
+			r.MinSongs = r.NSongs
+		}
+		rounds = append(rounds, db.Round{
+			Number:          uint(len(rounds) + 1),
+			Prompt:          r.Prompt,
+			Deadline:        r.Deadline,
+			RankingDeadline: r.RankingDeadline,
+			NSongs:          r.NSongs,
+			MinSongs:        r.MinSongs,
+		})
+	}
+	if err = db.ValidateRounds(rounds); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err = db.ValidatePlayers(g.MinPlayers, g.MaxPlayers); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err = db.ValidatePrompt(g.Prompt); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if len(g.Teams) > 0 {
+		if err = db.ValidateTeams(g.Teams); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+	teams := []db.Team{}
+	for _, name := range g.Teams {
+		teams = append(teams, db.Team{Name: name})
+	}
+	gameRules := []db.Rule{}
+	for _, r := range g.Rules {
+		gameRules = append(gameRules, db.Rule{Kind: r.Kind, Value: r.Value})
+	}
+	if err = rules.Validate(gameRules); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	playlist, err := utils.CreatePlaylist(g.Name)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
 
 	dbGame := db.Game{
-		Name:     g.Name,
-		Deadline: g.Deadline,
-		NSongs:   g.NSongs,
-		Playlist: playlistId.ID,
+		Name:            g.Name,
+		Deadline:        g.Deadline,
+		RankingDeadline: g.RankingDeadline,
+		NSongs:          g.NSongs,
+		MinSongs:        g.MinSongs,
+		MinPlayers:      g.MinPlayers,
+		MaxPlayers:      g.MaxPlayers,
+		Playlist:        playlist,
+		Scoring:         g.Scoring,
+		RankingMode:     g.RankingMode,
+		HostID:          uid,
+		Tiers:           g.Tiers,
+		Prompt:          g.Prompt,
+		Rules:           gameRules,
+		Teams:           teams,
+		Rounds:          rounds[1:],
+	}
+	if g.Draft {
+		dbGame.Phase = string(lifecycle.Draft)
 	}
 
 	err = conn.Create(&dbGame).Error
 	if err != nil {
 		return http.StatusBadRequest, err
 	}
+	err = db.AddMember(conn, dbGame.ID, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
 	g.ID = dbGame.ID
+	g.InviteToken = dbGame.InviteToken
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
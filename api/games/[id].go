@@ -5,18 +5,33 @@ import (
 	"errors"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/results"
 	"github.com/charliekim2/songsleuths/utils"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
 type Game struct {
-	ID       string `json:"id"`
-	Name     string `json:"name"`
-	Deadline uint   `json:"deadline"`
-	NSongs   uint   `json:"n_songs"`
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	Deadline        uint   `json:"deadline"`
+	RankingDeadline uint   `json:"ranking_deadline,omitempty"`
+	NSongs          uint   `json:"n_songs"`
+	MinSongs        uint   `json:"min_songs"`
+	MinPlayers      uint   `json:"min_players,omitempty"`
+	MaxPlayers      uint   `json:"max_players,omitempty"`
+	Phase           string `json:"phase"`
+	Round           uint   `json:"round"`
+	NRounds         uint   `json:"n_rounds"`
+	RankingMode     string `json:"ranking_mode"`
+	InviteToken     string `json:"invite_token,omitempty"` // Only shown to members
+	HostID          string `json:"host_id,omitempty"`
+	Prompt          string `json:"prompt,omitempty"`
+	Rules           []Rule `json:"rules,omitempty"`
+	Teams           []Team `json:"teams,omitempty"`
 
 	// The requesting players submission
 	Submission *Submission `json:"submission,omitempty"`
@@ -39,6 +54,16 @@ type Song struct {
 	Name     string `json:"name"`
 }
 
+type Rule struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value,omitempty"`
+}
+
+type Team struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
 type Tierlist struct {
 	ID    uint   `json:"id"`
 	Type  string `json:"type"`
@@ -52,6 +77,14 @@ type Tier struct {
 	Drawing string `json:"drawing,omitempty"`
 }
 
+// Fields the host can change, omitted fields are left alone
+type Settings struct {
+	Name     *string `json:"name,omitempty"`
+	NSongs   *uint   `json:"n_songs,omitempty"`
+	MinSongs *uint   `json:"min_songs,omitempty"`
+	Deadline *uint   `json:"deadline,omitempty"`
+}
+
 type Submission struct {
 	Songs    []string `json:"songs"`
 	Nickname string   `json:"nickname"`
@@ -64,6 +97,10 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method == http.MethodGet {
 		status, err = get(w, r)
+	} else if r.Method == http.MethodPatch {
+		status, err = patch(w, r)
+	} else if r.Method == http.MethodDelete {
+		status, err = remove(w, r)
 	}
 
 	if err != nil {
@@ -87,20 +124,49 @@ func get(w http.ResponseWriter, r *http.Request) (int, error) {
 	if res.Error != nil {
 		return http.StatusNotFound, res.Error
 	}
+	member, err := db.IsMember(conn, gid, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !member && game.HostID != uid {
+		return http.StatusForbidden, errors.New("join the game first")
+	}
+
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
 
 	g := Game{
-		ID:       game.ID,
-		Name:     game.Name,
-		Deadline: game.Deadline,
-		NSongs:   game.NSongs,
+		ID:              game.ID,
+		Name:            game.Name,
+		Deadline:        game.Deadline,
+		RankingDeadline: game.RankingDeadline,
+		NSongs:          game.NSongs,
+		MinSongs:        game.MinSongs,
+		MinPlayers:      game.MinPlayers,
+		MaxPlayers:      game.MaxPlayers,
+		RankingMode:     game.RankingMode,
+		HostID:          game.HostID,
+		Prompt:          game.Prompt,
+		Round:           game.CurrentRound,
+		NRounds:         game.NRounds,
 	}
-	if time.Now().Unix() > int64(game.Deadline) {
+	// Songs and tierlists are shown for the current round only
+	current := results.Round(game, game.CurrentRound)
+	for _, r := range game.Rules {
+		g.Rules = append(g.Rules, Rule{Kind: r.Kind, Value: r.Value})
+	}
+	for _, t := range game.Teams {
+		g.Teams = append(g.Teams, Team{ID: t.ID, Name: t.Name})
+	}
+	if phase != lifecycle.Draft && phase != lifecycle.Open {
 		g.Songs = []Song{}
 		g.GuessList = &Tierlist{Tiers: []Tier{}}
 		g.RankingList = &Tierlist{Tiers: []Tier{}}
 		// map nicknames to drawings, then set drawings on guess tiers
 		drawings := make(map[string]string)
-		for _, s := range game.Submissions {
+		for _, s := range current.Submissions {
 			drawings[s.Nickname] = s.Drawing
 			for _, song := range s.Songs {
 				g.Songs = append(g.Songs, Song{
@@ -112,20 +178,8 @@ func get(w http.ResponseWriter, r *http.Request) (int, error) {
 			}
 		}
 
-		// Add songs to Spotify playlist
-		if !game.AddedSongs {
-			err = addSongs(g.Songs, game.Playlist)
-			if err != nil {
-				return http.StatusInternalServerError, err
-			}
-			err = conn.Model(&db.Game{ID: game.ID}).Update("added_songs", true).Error
-			if err != nil {
-				return http.StatusInternalServerError, err
-			}
-		}
-
 		// Populate guess and ranking list data
-		for _, list := range game.Tierlists {
+		for _, list := range current.Tierlists {
 			if list.Type == "guess" {
 				g.GuessList.ID = list.ID
 				g.GuessList.Type = list.Type
@@ -153,7 +207,7 @@ func get(w http.ResponseWriter, r *http.Request) (int, error) {
 		g.Playlist = game.Playlist
 	} else {
 		sub := &db.Submission{}
-		res = conn.Where(&db.Submission{PlayerID: uid, GameID: gid}).Preload("Songs").First(sub)
+		res = conn.Where(&db.Submission{PlayerID: uid, GameID: gid, Round: game.CurrentRound}).Preload("Songs").First(sub)
 		if res.Error == nil {
 			g.Submission = &Submission{
 				Songs:    []string{},
@@ -166,20 +220,174 @@ func get(w http.ResponseWriter, r *http.Request) (int, error) {
 		}
 	}
 
+	g.Phase = string(phase)
+	g.InviteToken = game.InviteToken
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(g)
 	return http.StatusOK, nil
 }
 
-func addSongs(songs []Song, playlist string) error {
-	songIds := []string{}
-	for _, song := range songs {
-		songIds = append(songIds, song.Spotify)
+func patch(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	gid := strings.TrimPrefix(r.URL.Path, "/api/games/")
+	settings := &Settings{}
+	err = json.NewDecoder(r.Body).Decode(settings)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	game := &db.Game{}
+	err = conn.First(game, "id = ?", gid).Error
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	if game.HostID != uid {
+		return http.StatusForbidden, errors.New("only the host can change the game")
+	}
+
+	updates := map[string]any{}
+	if settings.Name != nil {
+		if err = db.ValidateName(*settings.Name); err != nil {
+			return http.StatusBadRequest, err
+		}
+		updates["name"] = *settings.Name
+	}
+	if settings.NSongs != nil || settings.MinSongs != nil {
+		n, min := game.NSongs, game.MinSongs
+		if settings.NSongs != nil {
+			n = *settings.NSongs
+			// Games asking for an exact number of songs keep doing so
+			if game.MinSongs == game.NSongs {
+				min = n
+			}
+		}
+		if settings.MinSongs != nil {
+			min = *settings.MinSongs
+		}
+		if err = db.ValidateSongRange(min, n); err != nil {
+			return http.StatusBadRequest, err
+		}
+		// Existing submissions were made for the old song count
+		var count int64
+		err = conn.Model(&db.Submission{}).
+			Where("game_id = ? AND round = ? AND player_id != ?", gid, game.CurrentRound, db.DecoyPlayer).
+			Count(&count).Error
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if count > 0 {
+			return http.StatusBadRequest, errors.New("cannot change number of songs after players have submitted")
+		}
+		updates["n_songs"] = n
+		updates["min_songs"] = min
+	}
+	if settings.Deadline != nil {
+		// Songs are already in the playlist, so submissions can't reopen
+		if game.AddedSongs {
+			return http.StatusBadRequest, errors.New("cannot move the deadline after songs were added to the playlist")
+		}
+		if err = db.ValidateDeadline(*settings.Deadline, game.RankingDeadline); err != nil {
+			return http.StatusBadRequest, err
+		}
+		// The round can't run into the ones after it
+		rounds := []db.Round{}
+		err = conn.Where("game_id = ? AND number >= ?", gid, game.CurrentRound).Order("number").Find(&rounds).Error
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if len(rounds) > 0 {
+			rounds[0].Deadline = *settings.Deadline
+			if err = db.ValidateRounds(rounds); err != nil {
+				return http.StatusBadRequest, err
+			}
+		}
+		updates["deadline"] = *settings.Deadline
+	}
+	if len(updates) == 0 {
+		return http.StatusBadRequest, errors.New("nothing to update")
+	}
+
+	err = conn.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&db.Game{ID: gid}).Updates(updates).Error; err != nil {
+			return err
+		}
+		// Keep the current round's record in step with the game
+		roundUpdates := map[string]any{}
+		for _, field := range []string{"n_songs", "min_songs", "deadline"} {
+			if value, ok := updates[field]; ok {
+				roundUpdates[field] = value
+			}
+		}
+		if len(roundUpdates) > 0 {
+			err := tx.Model(&db.Round{}).
+				Where("game_id = ? AND number = ?", gid, game.CurrentRound).
+				Updates(roundUpdates).Error
+			if err != nil {
+				return err
+			}
+		}
+		if settings.Deadline == nil {
+			return nil
+		}
+		return tx.Create(&db.DeadlineChange{
+			GameID:   gid,
+			PlayerID: uid,
+			Before:   game.Deadline,
+			After:    *settings.Deadline,
+		}).Error
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// A game that locked at the old deadline opens again
+	if settings.Deadline != nil && lifecycle.Phase(game.Phase) == lifecycle.Locked {
+		err = lifecycle.Transition(conn, game, lifecycle.Open)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return 0, nil
+}
+
+// Archives the game. The host can restore it until it is purged after
+// db.Retention, which is also when its Spotify playlist is deleted.
+func remove(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	gid := strings.TrimPrefix(r.URL.Path, "/api/games/")
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
 	}
-	err := utils.AddToPlaylist(songIds, playlist)
+	game := &db.Game{}
+	err = conn.First(game, "id = ?", gid).Error
 	if err != nil {
-		return err
+		return http.StatusNotFound, err
+	}
+	if game.HostID != uid {
+		return http.StatusForbidden, errors.New("only the host can delete the game")
 	}
-	return nil
+
+	err = lifecycle.Archive(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return 0, nil
 }
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/utils"
+	"gorm.io/gorm"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusMethodNotAllowed
+	err := errors.New("Invalid request method")
+
+	if r.Method == http.MethodPost {
+		status, err = post(w, r)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+// Closes submissions before the deadline, or ranking before the ranking
+// deadline, which reveals the results. Games without a ranking deadline
+// would otherwise wait on every player forever.
+func post(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	gid := strings.TrimPrefix(r.URL.Path, "/api/games/close/")
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	game := &db.Game{}
+	err = conn.First(game, "id = ?", gid).Error
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	if game.HostID != uid {
+		return http.StatusForbidden, errors.New("only the host can close the game")
+	}
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if phase == lifecycle.Ranking {
+		return closeRanking(w, conn, game)
+	}
+	if err = lifecycle.Check(phase, lifecycle.Open); err != nil {
+		return http.StatusBadRequest, err
+	}
+	quorum, err := lifecycle.HasQuorum(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !quorum {
+		return http.StatusBadRequest, fmt.Errorf("at least %d players must submit first", game.MinPlayers)
+	}
+
+	// Move the deadline up so it still says when submissions closed
+	err = conn.Model(&db.Game{ID: gid}).Update("deadline", uint(time.Now().Unix())).Error
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = lifecycle.Transition(conn, game, lifecycle.Locked)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return 0, nil
+}
+
+func closeRanking(w http.ResponseWriter, conn *gorm.DB, game *db.Game) (int, error) {
+	// Same as submissions, the ranking deadline records when ranking closed
+	err := conn.Model(&db.Game{ID: game.ID}).Update("ranking_deadline", uint(time.Now().Unix())).Error
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	err = lifecycle.Transition(conn, game, lifecycle.Revealed)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return 0, nil
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/utils"
+	"gorm.io/gorm"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusMethodNotAllowed
+	err := errors.New("Invalid request method")
+
+	if r.Method == http.MethodPost {
+		status, err = post(w, r)
+	} else if r.Method == http.MethodDelete {
+		status, err = remove(w, r)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+type Decoys struct {
+	Songs []string `json:"songs"` // Spotify track ids
+}
+
+// hostGame loads the game for a request only its host may make while
+// submissions are open
+func hostGame(r *http.Request, conn *gorm.DB) (*db.Game, int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return nil, http.StatusUnauthorized, err
+	}
+	gid := strings.TrimPrefix(r.URL.Path, "/api/games/decoys/")
+
+	game := &db.Game{}
+	err = conn.First(game, "id = ?", gid).Error
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	if game.HostID != uid {
+		return nil, http.StatusForbidden, errors.New("only the host can manage decoys")
+	}
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if err = lifecycle.Check(phase, lifecycle.Draft, lifecycle.Open); err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	return game, 0, nil
+}
+
+// Adds decoy songs that belong to no player to the current round. They go in
+// the playlist with everyone else's and are guessed under their own tier.
+func post(w http.ResponseWriter, r *http.Request) (int, error) {
+	decoys := &Decoys{}
+	err := json.NewDecoder(r.Body).Decode(decoys)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if len(decoys.Songs) == 0 {
+		return http.StatusBadRequest, errors.New("no songs given")
+	}
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	game, status, err := hostGame(r, conn)
+	if err != nil {
+		return status, err
+	}
+
+	sub := &db.Submission{}
+	err = conn.Where("game_id = ? AND player_id = ? AND round = ?", game.ID, db.DecoyPlayer, game.CurrentRound).
+		Preload("Songs").
+		First(sub).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusInternalServerError, err
+	}
+	if len(sub.Songs)+len(decoys.Songs) > db.MaxDecoys {
+		return http.StatusBadRequest, errors.New(fmt.Sprintf("a game can have at most %d decoys", db.MaxDecoys))
+	}
+
+	var songs []db.Song
+	covers, err := utils.GetAlbumArt(decoys.Songs)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	for _, cover := range covers {
+		songs = append(songs, db.Song{
+			SubmissionID: sub.ID,
+			Spotify:      cover.ID,
+			AlbumArt:     cover.URL,
+			Name:         cover.Name,
+			GameID:       game.ID,
+		})
+	}
+
+	if sub.ID == 0 {
+		err = conn.Create(&db.Submission{
+			PlayerID: db.DecoyPlayer,
+			GameID:   game.ID,
+			Nickname: db.DecoyNickname,
+			Songs:    songs,
+			Round:    game.CurrentRound,
+		}).Error
+	} else {
+		err = conn.Create(&songs).Error
+	}
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	return 0, nil
+}
+
+// Removes the decoy with the Spotify id in ?song=. The decoy tier goes
+// with the last one.
+func remove(w http.ResponseWriter, r *http.Request) (int, error) {
+	song := r.URL.Query().Get("song")
+	if song == "" {
+		return http.StatusBadRequest, errors.New("no song given")
+	}
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	game, status, err := hostGame(r, conn)
+	if err != nil {
+		return status, err
+	}
+
+	sub := &db.Submission{}
+	err = conn.Where("game_id = ? AND player_id = ? AND round = ?", game.ID, db.DecoyPlayer, game.CurrentRound).
+		Preload("Songs").
+		First(sub).Error
+	if err != nil {
+		return http.StatusNotFound, errors.New("round has no decoys")
+	}
+	found := false
+	for _, s := range sub.Songs {
+		found = found || s.Spotify == song
+	}
+	if !found {
+		return http.StatusNotFound, errors.New("song is not a decoy")
+	}
+
+	if len(sub.Songs) == 1 {
+		err = conn.Unscoped().Delete(sub).Error
+	} else {
+		err = conn.Unscoped().Where("submission_id = ? AND spotify = ?", sub.ID, song).Delete(&db.Song{}).Error
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return 0, nil
+}
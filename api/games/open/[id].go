@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/utils"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusMethodNotAllowed
+	err := errors.New("Invalid request method")
+
+	if r.Method == http.MethodPost {
+		status, err = post(w, r)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+// Opens a draft game for submissions
+func post(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	gid := strings.TrimPrefix(r.URL.Path, "/api/games/open/")
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	game := &db.Game{}
+	err = conn.First(game, "id = ?", gid).Error
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	if game.HostID != uid {
+		return http.StatusForbidden, errors.New("only the host can open the game")
+	}
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = lifecycle.Check(phase, lifecycle.Draft); err != nil {
+		return http.StatusBadRequest, err
+	}
+	// A draft can sit past its deadline, which would lock it straight away
+	if err = db.ValidateDeadline(game.Deadline, game.RankingDeadline); err != nil {
+		return http.StatusBadRequest, errors.New("move the deadline before opening the game")
+	}
+
+	err = lifecycle.Transition(conn, game, lifecycle.Open)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return 0, nil
+}
@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/utils"
+	"gorm.io/gorm"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusMethodNotAllowed
+	err := errors.New("Invalid request method")
+
+	if r.Method == http.MethodPost {
+		status, err = post(w, r)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+// Settings that can't be copied from the finished game
+type Rematch struct {
+	Name            string `json:"name,omitempty"` // Defaults to the original name
+	Deadline        uint   `json:"deadline"`
+	RankingDeadline uint   `json:"ranking_deadline,omitempty"`
+}
+
+type Created struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	InviteToken string `json:"invite_token"`
+}
+
+// Starts a new game with the same settings and members as a finished one.
+// The caller hosts the new game.
+func post(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	gid := strings.TrimPrefix(r.URL.Path, "/api/games/rematch/")
+	rematch := &Rematch{}
+	err = json.NewDecoder(r.Body).Decode(rematch)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	game := &db.Game{}
+	err = conn.Preload("Tierlists.Tiers").Preload("Rules").Preload("Teams").Preload("Players").
+		Preload("Rounds", func(tx *gorm.DB) *gorm.DB { return tx.Order("number") }).
+		First(game, "id = ?", gid).Error
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	member, err := db.IsMember(conn, gid, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !member {
+		return http.StatusForbidden, errors.New("only members can start a rematch")
+	}
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = lifecycle.Check(phase, lifecycle.Revealed, lifecycle.Archived); err != nil {
+		return http.StatusBadRequest, errors.New("game has not finished yet")
+	}
+
+	if rematch.Name == "" {
+		rematch.Name = game.Name
+	}
+	if err = db.ValidateName(rematch.Name); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err = db.ValidateDeadline(rematch.Deadline, rematch.RankingDeadline); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	// Custom tiers live on the ranking tierlist, in rank order. Every round
+	// has the same ones.
+	tiers := []string{}
+	for _, list := range game.Tierlists {
+		if list.Type != "ranking" || list.Round != 1 {
+			continue
+		}
+		sort.Slice(list.Tiers, func(i, j int) bool { return list.Tiers[i].Rank < list.Tiers[j].Rank })
+		for _, tier := range list.Tiers {
+			tiers = append(tiers, tier.Name)
+		}
+	}
+	rules := []db.Rule{}
+	for _, rule := range game.Rules {
+		rules = append(rules, db.Rule{Kind: rule.Kind, Value: rule.Value})
+	}
+
+	teams := []db.Team{}
+	for _, team := range game.Teams {
+		teams = append(teams, db.Team{Name: team.Name})
+	}
+
+	// By the end the game's own settings are its last round's, so the first
+	// round's come from its record. Later rounds keep their spacing.
+	first := db.Round{Prompt: game.Prompt, NSongs: game.NSongs, MinSongs: game.MinSongs}
+	rounds := []db.Round{}
+	for i, round := range game.Rounds {
+		if i == 0 {
+			first = round
+			continue
+		}
+		shift := func(deadline uint) uint {
+			if deadline == 0 {
+				return 0
+			}
+			return deadline - first.Deadline + rematch.Deadline
+		}
+		rounds = append(rounds, db.Round{
+			Prompt:          round.Prompt,
+			Deadline:        shift(round.Deadline),
+			RankingDeadline: shift(round.RankingDeadline),
+			NSongs:          round.NSongs,
+			MinSongs:        round.MinSongs,
+		})
+	}
+
+	playlist, err := utils.CreatePlaylist(rematch.Name)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	next := db.Game{
+		Name:            rematch.Name,
+		Deadline:        rematch.Deadline,
+		RankingDeadline: rematch.RankingDeadline,
+		NSongs:          first.NSongs,
+		MinSongs:        first.MinSongs,
+		MinPlayers:      game.MinPlayers,
+		MaxPlayers:      game.MaxPlayers,
+		Playlist:        playlist,
+		Scoring:         game.Scoring,
+		RankingMode:     game.RankingMode,
+		HostID:          uid,
+		Tiers:           tiers,
+		Prompt:          first.Prompt,
+		Rounds:          rounds,
+		Rules:           rules,
+		Teams:           teams,
+		LeagueID:        game.LeagueID,
+		RematchOf:       &game.ID,
+	}
+	err = conn.Create(&next).Error
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	// Everyone from the original game is invited automatically, as far as the
+	// new game has room. Anyone left over can join with the invite.
+	err = db.AddMember(conn, next.ID, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	members := uint(1)
+	for _, p := range game.Players {
+		if p.ID == uid {
+			continue
+		}
+		if members >= next.PlayerLimit() {
+			break
+		}
+		err = db.AddMember(conn, next.ID, p.ID)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		members++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(Created{ID: next.ID, Name: next.Name, InviteToken: next.InviteToken})
+	return http.StatusCreated, nil
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/utils"
+	"gorm.io/gorm"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusMethodNotAllowed
+	err := errors.New("Invalid request method")
+
+	if r.Method == http.MethodPost && r.URL.Query().Get("player") != "" {
+		status, err = restoreSubmission(w, r)
+	} else if r.Method == http.MethodPost {
+		status, err = restoreGame(w, r)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+// Brings back a game the host archived, if it hasn't been purged yet
+func restoreGame(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	gid := strings.TrimPrefix(r.URL.Path, "/api/games/restore/")
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	game := &db.Game{}
+	err = conn.Unscoped().First(game, "id = ? AND deleted_at IS NOT NULL", gid).Error
+	if err != nil {
+		return http.StatusNotFound, errors.New("no archived game to restore")
+	}
+	if game.HostID != uid {
+		return http.StatusForbidden, errors.New("only the host can restore the game")
+	}
+	if time.Since(game.DeletedAt.Time) > db.Retention {
+		return http.StatusGone, errors.New("game was archived too long ago to restore")
+	}
+
+	err = conn.Unscoped().Model(&db.Game{ID: gid}).Update("deleted_at", nil).Error
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return 0, nil
+}
+
+// Brings back a removed submission. Players can restore their own while the
+// game is open, and the host can restore anyone's until songs reach the
+// playlist, the same as removing them.
+func restoreSubmission(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	gid := strings.TrimPrefix(r.URL.Path, "/api/games/restore/")
+	player := r.URL.Query().Get("player")
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	game := &db.Game{}
+	err = conn.First(game, "id = ?", gid).Error
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	allowed := []lifecycle.Phase{lifecycle.Open}
+	if player != uid {
+		if game.HostID != uid {
+			return http.StatusForbidden, errors.New("only the host can restore other submissions")
+		}
+		allowed = append(allowed, lifecycle.Locked)
+	}
+	if err = lifecycle.Check(phase, allowed...); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	err = db.RestoreSubmission(conn, gid, player, game.CurrentRound)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound, errors.New("no archived submission to restore")
+	}
+	if err != nil {
+		return http.StatusConflict, err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return 0, nil
+}
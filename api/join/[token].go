@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/utils"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusMethodNotAllowed
+	err := errors.New("Invalid request method")
+
+	if r.Method == http.MethodPost {
+		status, err = post(w, r)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+type Joined struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func post(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	token := strings.TrimPrefix(r.URL.Path, "/api/join/")
+	if token == "" {
+		return http.StatusNotFound, errors.New("invalid invite")
+	}
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	game := &db.Game{}
+	err = conn.First(game, "invite_token = ?", token).Error
+	if err != nil {
+		return http.StatusNotFound, errors.New("invalid invite")
+	}
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = lifecycle.Check(phase, lifecycle.Draft, lifecycle.Open); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	member, err := db.IsMember(conn, game.ID, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !member {
+		members, err := db.CountMembers(conn, game.ID)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if members >= int64(game.PlayerLimit()) {
+			return http.StatusConflict, errors.New("game is full")
+		}
+	}
+
+	err = db.AddMember(conn, game.ID, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Joined{ID: game.ID, Name: game.Name})
+	return http.StatusOK, nil
+}
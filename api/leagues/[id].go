@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/results"
+	"github.com/charliekim2/songsleuths/utils"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	status, err := http.StatusMethodNotAllowed, errors.New("Invalid request method")
+
+	if r.Method == http.MethodGet {
+		status, err = get(w, r)
+	} else if r.Method == http.MethodPost {
+		status, err = post(w, r)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+type League struct {
+	ID        string             `json:"id"`
+	Name      string             `json:"name"`
+	OwnerID   string             `json:"owner_id"`
+	Games     []Game             `json:"games"`
+	Members   []Member           `json:"members"`
+	Standings []results.Standing `json:"standings"` // Only revealed games count
+}
+
+type Game struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Deadline uint   `json:"deadline"`
+	Phase    string `json:"phase"`
+}
+
+type Member struct {
+	PlayerID string `json:"player_id"`
+	Nickname string `json:"nickname,omitempty"`
+}
+
+type Attach struct {
+	GameID string `json:"game_id"`
+}
+
+// Shows a league to its owner and the members of its games
+func get(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/leagues/")
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	league, err := results.LoadLeague(conn, id)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	member, err := db.IsLeagueMember(conn, league, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !member {
+		return http.StatusForbidden, errors.New("join one of the league's games first")
+	}
+
+	l := League{
+		ID:      league.ID,
+		Name:    league.Name,
+		OwnerID: league.OwnerID,
+		Games:   []Game{},
+		Members: []Member{},
+	}
+	revealed := []*db.Game{}
+	// Anyone who submitted or ranked in one of the league's games is a member
+	nicknames := map[string]string{league.OwnerID: ""}
+	for i := range league.Games {
+		game := &league.Games[i]
+		phase, err := lifecycle.Sync(conn, game)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if lifecycle.Check(phase, lifecycle.Revealed, lifecycle.Archived) == nil {
+			revealed = append(revealed, game)
+		}
+		l.Games = append(l.Games, Game{
+			ID:       game.ID,
+			Name:     game.Name,
+			Deadline: game.Deadline,
+			Phase:    string(phase),
+		})
+
+		for _, ranking := range game.Rankings {
+			if _, ok := nicknames[ranking.PlayerID]; !ok {
+				nicknames[ranking.PlayerID] = ""
+			}
+		}
+		for _, s := range game.Submissions {
+			if s.PlayerID != db.DecoyPlayer {
+				nicknames[s.PlayerID] = s.Nickname
+			}
+		}
+	}
+	for pid, nickname := range nicknames {
+		l.Members = append(l.Members, Member{PlayerID: pid, Nickname: nickname})
+	}
+	sort.Slice(l.Members, func(i, j int) bool { return l.Members[i].PlayerID < l.Members[j].PlayerID })
+	sort.Slice(l.Games, func(i, j int) bool { return l.Games[i].Deadline < l.Games[j].Deadline })
+
+	l.Standings, err = results.Standings(revealed)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(l)
+	return http.StatusOK, nil
+}
+
+func post(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/leagues/")
+	attach := &Attach{}
+	err = json.NewDecoder(r.Body).Decode(attach)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	league := &db.League{}
+	err = conn.First(league, "id = ?", id).Error
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	if league.OwnerID != uid {
+		return http.StatusForbidden, errors.New("only the league owner can add games")
+	}
+
+	game := &db.Game{}
+	err = conn.First(game, "id = ?", attach.GameID).Error
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	// Otherwise anyone's game could be shown to the league
+	if game.HostID != uid {
+		return http.StatusForbidden, errors.New("only the game's host can add it to a league")
+	}
+	if game.LeagueID != nil && *game.LeagueID != league.ID {
+		return http.StatusConflict, errors.New("game already belongs to another league")
+	}
+
+	err = conn.Model(&db.Game{ID: game.ID}).Update("league_id", league.ID).Error
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return 0, nil
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/utils"
+)
+
+// Purge permanently deletes games and submissions archived longer than
+// db.Retention ago, along with archived games' playlists. Run daily by the
+// cron in vercel.json, which sends CRON_SECRET as a bearer token.
+func Purge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	secret := os.Getenv("CRON_SECRET")
+	if secret == "" || r.Header.Get("Authorization") != "Bearer "+secret {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := db.Connect()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	cutoff := time.Now().Add(-db.Retention)
+	games, err := db.ArchivedBefore(conn, cutoff)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, g := range games {
+		// Playlist goes first so a failure leaves the game to retry next run
+		if err = utils.DeletePlaylist(g.Playlist); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err = db.PurgeGame(conn, g.ID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	if err = db.PurgeSubmissions(conn, cutoff); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
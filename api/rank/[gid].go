@@ -3,18 +3,23 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"math/rand/v2"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/results"
 	"github.com/charliekim2/songsleuths/utils"
+	"gorm.io/gorm"
 )
 
 func Handler(w http.ResponseWriter, r *http.Request) {
 	status, err := http.StatusMethodNotAllowed, errors.New("Invalid request method")
 
-	if r.Method == http.MethodPost {
+	if r.Method == http.MethodGet {
+		status, err = get(w, r)
+	} else if r.Method == http.MethodPost {
 		status, err = post(w, r)
 	}
 
@@ -26,6 +31,100 @@ func Handler(w http.ResponseWriter, r *http.Request) {
 type Ranking struct {
 	TierlistID uint   `json:"tierlist_id"`
 	Ranking    string `json:"ranking"`
+
+	// Head-to-head vote in pairwise games, sent instead of a tierlist ranking
+	Winner uint `json:"winner,omitempty"`
+	Loser  uint `json:"loser,omitempty"`
+}
+
+type Pair struct {
+	A Song `json:"a"`
+	B Song `json:"b"`
+}
+
+type Song struct {
+	ID       uint   `json:"id"`
+	Spotify  string `json:"spotify"`
+	AlbumArt string `json:"album_art"`
+	Name     string `json:"name"`
+}
+
+// Serves a random pair of songs the player has not compared yet
+func get(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	gid := strings.TrimPrefix(r.URL.Path, "/api/rank/")
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	member, err := db.IsMember(conn, gid, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !member {
+		return http.StatusForbidden, errors.New("join the game first")
+	}
+	game, err := results.LoadGame(conn, gid)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	if game.RankingMode != "pairwise" {
+		return http.StatusBadRequest, errors.New("game does not use pairwise ranking")
+	}
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = lifecycle.Check(phase, lifecycle.Ranking); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	voted := make(map[[2]uint]bool)
+	for _, v := range game.Votes {
+		if v.PlayerID == uid {
+			voted[[2]uint{min(v.WinnerID, v.LoserID), max(v.WinnerID, v.LoserID)}] = true
+		}
+	}
+	songs := []db.Song{}
+	for _, s := range results.Round(game, game.CurrentRound).Submissions {
+		songs = append(songs, s.Songs...)
+	}
+	pairs := [][2]db.Song{}
+	for i := range songs {
+		for j := i + 1; j < len(songs); j++ {
+			a, b := songs[i], songs[j]
+			if !voted[[2]uint{min(a.ID, b.ID), max(a.ID, b.ID)}] {
+				pairs = append(pairs, [2]db.Song{a, b})
+			}
+		}
+	}
+	if len(pairs) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return 0, nil
+	}
+
+	pair := pairs[rand.IntN(len(pairs))]
+	if rand.IntN(2) == 0 {
+		pair[0], pair[1] = pair[1], pair[0]
+	}
+	toSong := func(song db.Song) Song {
+		return Song{
+			ID:       song.ID,
+			Spotify:  song.Spotify,
+			AlbumArt: song.AlbumArt,
+			Name:     song.Name,
+		}
+	}
+	res := Pair{A: toSong(pair[0]), B: toSong(pair[1])}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(res)
+	return http.StatusOK, nil
 }
 
 func post(w http.ResponseWriter, r *http.Request) (int, error) {
@@ -44,30 +143,114 @@ func post(w http.ResponseWriter, r *http.Request) (int, error) {
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	member, err := db.IsMember(conn, gid, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !member {
+		return http.StatusForbidden, errors.New("join the game first")
+	}
 
-	game := &db.Game{}
-	err = conn.First(game, "id = ?", gid).Error
+	game, err := results.LoadGame(conn, gid)
 	if err != nil {
 		return http.StatusNotFound, err
 	}
-	if time.Now().Unix() < int64(game.Deadline) {
-		return http.StatusBadRequest, errors.New("cannot rank before deadline")
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = lifecycle.Check(phase, lifecycle.Ranking); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if ranking.Winner != 0 || ranking.Loser != 0 {
+		return vote(w, conn, game, uid, ranking)
 	}
 
-	// Cannot resubmit ranking
-	// err = conn.Where("player_id = ? and tierlist_id = ?", uid, ranking.TierlistID).Delete(&db.Ranking{}).Error
-	// if err != nil {
-	// 	return http.StatusInternalServerError, err
-	// }
+	// Earlier rounds' rankings are final
+	for _, list := range game.Tierlists {
+		if list.ID == ranking.TierlistID && list.Round != game.CurrentRound {
+			return http.StatusBadRequest, errors.New("tierlist belongs to an earlier round")
+		}
+	}
 
-	dbRanking := db.Ranking{
-		PlayerID:   uid,
-		TierlistID: ranking.TierlistID,
-		GameID:     gid,
-		Ranking:    ranking.Ranking,
+	// Field-level errors are returned as JSON so the client can point at them
+	if verr := results.ValidateRanking(game, uid, ranking.TierlistID, ranking.Ranking); verr != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(verr)
+		return http.StatusBadRequest, nil
 	}
-	err = conn.Create(&dbRanking).Error
+
+	// Rankings can be revised until ranking closes, each version is kept
+	status := http.StatusCreated
+	existing := &db.Ranking{}
+	err = conn.Where("player_id = ? and tierlist_id = ?", uid, ranking.TierlistID).First(existing).Error
+	if err == nil {
+		existing.Ranking = ranking.Ranking
+		err = conn.Model(existing).Update("ranking", ranking.Ranking).Error
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		status = http.StatusOK
+	} else if errors.Is(err, gorm.ErrRecordNotFound) {
+		dbRanking := db.Ranking{
+			PlayerID:   uid,
+			TierlistID: ranking.TierlistID,
+			GameID:     gid,
+			Ranking:    ranking.Ranking,
+		}
+		err = conn.Create(&dbRanking).Error
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+	} else {
+		return http.StatusInternalServerError, err
+	}
+	if err = lifecycle.RevealWhenRanked(conn, game); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.WriteHeader(status)
+	return 0, nil
+}
+
+func vote(w http.ResponseWriter, conn *gorm.DB, game *db.Game, uid string, ranking *Ranking) (int, error) {
+	if game.RankingMode != "pairwise" {
+		return http.StatusBadRequest, errors.New("game does not use pairwise ranking")
+	}
+
+	// Earlier rounds' results are final, so only this round's songs can be voted on
+	songs := make(map[uint]bool)
+	for _, s := range results.Round(game, game.CurrentRound).Submissions {
+		for _, song := range s.Songs {
+			songs[song.ID] = true
+		}
+	}
+	if !songs[ranking.Winner] || !songs[ranking.Loser] {
+		return http.StatusBadRequest, errors.New("songs are not in the current round")
+	}
+
+	// Voting on the same pair again replaces the earlier vote
+	err := conn.Transaction(func(tx *gorm.DB) error {
+		err := tx.Unscoped().
+			Where("game_id = ? AND player_id = ? AND ((winner_id = ? AND loser_id = ?) OR (winner_id = ? AND loser_id = ?))",
+				game.ID, uid, ranking.Winner, ranking.Loser, ranking.Loser, ranking.Winner).
+			Delete(&db.PairwiseVote{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(&db.PairwiseVote{
+			GameID:   game.ID,
+			PlayerID: uid,
+			WinnerID: ranking.Winner,
+			LoserID:  ranking.Loser,
+		}).Error
+	})
 	if err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err = lifecycle.RevealWhenRanked(conn, game); err != nil {
 		return http.StatusInternalServerError, err
 	}
 
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/utils"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusMethodNotAllowed
+	err := errors.New("Invalid request method")
+
+	if r.Method == http.MethodGet {
+		status, err = get(w, r)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+type History struct {
+	PlayerID   string     `json:"player_id"`
+	Nickname   string     `json:"nickname"`
+	TierlistID uint       `json:"tierlist_id"`
+	Type       string     `json:"type"`
+	Revisions  []Revision `json:"revisions"`
+}
+
+type Revision struct {
+	Ranking   string    `json:"ranking"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func get(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	gid := strings.TrimPrefix(r.URL.Path, "/api/rank/history/")
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	game := &db.Game{}
+	err = conn.Preload("Tierlists").Preload("Submissions").First(game, "id = ?", gid).Error
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	member, err := db.IsMember(conn, gid, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !member && game.HostID != uid {
+		return http.StatusForbidden, errors.New("join the game first")
+	}
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// Everyone only sees their own history until results are revealed. The host
+	// plays too, so they wait as well.
+	query := conn.Where("game_id = ?", gid)
+	if lifecycle.Check(phase, lifecycle.Revealed, lifecycle.Archived) != nil {
+		query = query.Where("player_id = ?", uid)
+	}
+	revisions := []db.RankingRevision{}
+	err = query.Order("created_at").Find(&revisions).Error
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	types := make(map[uint]string)
+	for _, list := range game.Tierlists {
+		types[list.ID] = list.Type
+	}
+	nicknames := make(map[string]string)
+	for _, s := range game.Submissions {
+		nicknames[s.PlayerID] = s.Nickname
+	}
+	history := []History{}
+	index := make(map[uint]int) // Ranking id -> position in history
+	for _, rev := range revisions {
+		i, ok := index[rev.RankingID]
+		if !ok {
+			i = len(history)
+			index[rev.RankingID] = i
+			history = append(history, History{
+				PlayerID:   rev.PlayerID,
+				Nickname:   nicknames[rev.PlayerID],
+				TierlistID: rev.TierlistID,
+				Type:       types[rev.TierlistID],
+				Revisions:  []Revision{},
+			})
+		}
+		history[i].Revisions = append(history[i].Revisions, Revision{
+			Ranking:   rev.Ranking,
+			CreatedAt: rev.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(history)
+	return http.StatusOK, nil
+}
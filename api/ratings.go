@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/utils"
+)
+
+type rating struct {
+	PlayerID string  `json:"player_id"`
+	Nickname string  `json:"nickname"` // Most recent nickname the player used
+	Rating   float64 `json:"rating"`
+	Games    uint    `json:"games"`
+	Rank     int     `json:"rank"`
+}
+
+// Ratings serves the leaderboard of rated players the caller has played with
+func Ratings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Invalid request method", http.StatusMethodNotAllowed)
+		return
+	}
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	conn, err := db.Connect()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	players := []db.Player{}
+	err = conn.Where("rated_games > 0 AND id IN (?)", db.CoPlayers(conn, uid)).
+		Order("rating desc").
+		Limit(100).
+		Find(&players).Error
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	ids := []string{}
+	for _, p := range players {
+		ids = append(ids, p.ID)
+	}
+	submissions := []db.Submission{}
+	err = conn.Where("player_id IN ?", ids).Order("created_at").Find(&submissions).Error
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	nicknames := make(map[string]string)
+	for _, s := range submissions {
+		nicknames[s.PlayerID] = s.Nickname
+	}
+
+	leaderboard := []rating{}
+	for i, p := range players {
+		leaderboard = append(leaderboard, rating{
+			PlayerID: p.ID,
+			Nickname: nicknames[p.ID],
+			Rating:   p.Rating,
+			Games:    p.RatedGames,
+			Rank:     i + 1,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(leaderboard)
+}
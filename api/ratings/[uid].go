@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/utils"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusMethodNotAllowed
+	err := errors.New("Invalid request method")
+
+	if r.Method == http.MethodGet {
+		status, err = get(w, r)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+type Rating struct {
+	PlayerID string   `json:"player_id"`
+	Rating   float64  `json:"rating"`
+	Games    uint     `json:"games"`
+	History  []Change `json:"history"`
+}
+
+type Change struct {
+	GameID    string    `json:"game_id"`
+	GameName  string    `json:"game_name"`
+	Before    float64   `json:"before"`
+	After     float64   `json:"after"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Serves a player's rating history to themselves and anyone they've played with
+func get(w http.ResponseWriter, r *http.Request) (int, error) {
+	caller, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	uid := strings.TrimPrefix(r.URL.Path, "/api/ratings/")
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	shared, err := db.SharesGame(conn, caller, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !shared {
+		return http.StatusForbidden, errors.New("you have not played with this player")
+	}
+	player := &db.Player{}
+	err = conn.First(player, "id = ?", uid).Error
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	changes := []db.RatingChange{}
+	err = conn.Where("player_id = ?", uid).Order("created_at").Find(&changes).Error
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	gids := []string{}
+	for _, c := range changes {
+		gids = append(gids, c.GameID)
+	}
+	games := []db.Game{}
+	err = conn.Select("id", "name").Where("id IN ?", gids).Find(&games).Error
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	names := make(map[string]string)
+	for _, g := range games {
+		names[g.ID] = g.Name
+	}
+
+	rating := Rating{
+		PlayerID: player.ID,
+		Rating:   player.Rating,
+		Games:    player.RatedGames,
+		History:  []Change{},
+	}
+	for _, c := range changes {
+		rating.History = append(rating.History, Change{
+			GameID:    c.GameID,
+			GameName:  names[c.GameID],
+			Before:    c.Before,
+			After:     c.After,
+			CreatedAt: c.CreatedAt,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(rating)
+	return http.StatusOK, nil
+}
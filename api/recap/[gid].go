@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/recap"
+	"github.com/charliekim2/songsleuths/results"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusMethodNotAllowed
+	err := errors.New("Invalid request method")
+
+	if r.Method == http.MethodGet {
+		status, err = get(w, r)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+// Serves a PNG recap of a revealed game. No auth so chat apps can unfurl it.
+func get(w http.ResponseWriter, r *http.Request) (int, error) {
+	gid := strings.TrimPrefix(r.URL.Path, "/api/recap/")
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	game, err := results.LoadGame(conn, gid)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = lifecycle.Check(phase, lifecycle.Revealed, lifecycle.Archived); err != nil {
+		return http.StatusForbidden, errors.New("results are not revealed yet")
+	}
+
+	// Results can't change once revealed, so the image only depends on the game
+	etag := fmt.Sprintf("\"%s-%s\"", game.ID, phase)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "public, max-age=3600, s-maxage=86400")
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return 0, nil
+	}
+
+	scores, err := results.ScoreGuesses(game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	consensus, err := results.ScoreSongs(game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	drawings := make(map[string]string)
+	for _, s := range game.Submissions {
+		drawings[s.PlayerID] = s.Drawing
+	}
+	img, err := recap.New(game.Name, scores, consensus, drawings).PNG()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.WriteHeader(http.StatusOK)
+	w.Write(img)
+	return http.StatusOK, nil
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/results"
+	"github.com/charliekim2/songsleuths/utils"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusMethodNotAllowed
+	err := errors.New("Invalid request method")
+
+	if r.Method == http.MethodGet {
+		status, err = get(w, r)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+type Result struct {
+	Guesses  []results.Score     `json:"guesses"`
+	Rankings *results.Consensus  `json:"rankings"`
+	Teams    []results.TeamScore `json:"teams,omitempty"`
+}
+
+// Scores the whole game, or one round of it with ?round=. A round's results
+// are shown once that round has been revealed, before the game is over.
+func get(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	gid := strings.TrimPrefix(r.URL.Path, "/api/result/")
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	member, err := db.IsMember(conn, gid, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !member {
+		return http.StatusForbidden, errors.New("join the game first")
+	}
+	game, err := results.LoadGame(conn, gid)
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if param := r.URL.Query().Get("round"); param != "" {
+		n, err := strconv.ParseUint(param, 10, 32)
+		if err != nil || n < 1 || uint(n) > game.NRounds {
+			return http.StatusBadRequest, errors.New("no such round")
+		}
+		// Later rounds stay hidden until their own ranking closes
+		finished := uint(n) < game.CurrentRound
+		if !finished && lifecycle.Check(phase, lifecycle.Revealed, lifecycle.Archived) != nil {
+			return http.StatusForbidden, errors.New("round results are not revealed yet")
+		}
+		game = results.Round(game, uint(n))
+	} else if err = lifecycle.Check(phase, lifecycle.Revealed, lifecycle.Archived); err != nil {
+		// Results stay hidden until ranking closes
+		return http.StatusForbidden, errors.New("results are not revealed yet")
+	}
+	scores, err := results.ScoreGuesses(game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	consensus, err := results.ScoreSongs(game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	teams, err := results.ScoreTeams(game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Result{Guesses: scores, Rankings: consensus, Teams: teams})
+	return http.StatusOK, nil
+}
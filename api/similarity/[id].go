@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/results"
+	"github.com/charliekim2/songsleuths/utils"
+)
+
+func Handler(w http.ResponseWriter, r *http.Request) {
+	status := http.StatusMethodNotAllowed
+	err := errors.New("Invalid request method")
+
+	if r.Method == http.MethodGet {
+		status, err = get(w, r)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), status)
+	}
+}
+
+// Compares players' taste in a game, or across a league with ?scope=league.
+// Only the game's or league's members can see it.
+func get(w http.ResponseWriter, r *http.Request) (int, error) {
+	uid, err := utils.Authenticate(r)
+	if err != nil {
+		return http.StatusUnauthorized, err
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/similarity/")
+
+	conn, err := db.Connect()
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// Rankings stay private until a game's results are revealed
+	games := []*db.Game{}
+	if r.URL.Query().Get("scope") == "league" {
+		league, err := results.LoadLeague(conn, id)
+		if err != nil {
+			return http.StatusNotFound, err
+		}
+		member, err := db.IsLeagueMember(conn, league, uid)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if !member {
+			return http.StatusForbidden, errors.New("join one of the league's games first")
+		}
+		for i := range league.Games {
+			phase, err := lifecycle.Sync(conn, &league.Games[i])
+			if err != nil {
+				return http.StatusInternalServerError, err
+			}
+			if lifecycle.Check(phase, lifecycle.Revealed, lifecycle.Archived) == nil {
+				games = append(games, &league.Games[i])
+			}
+		}
+	} else {
+		game, err := results.LoadGame(conn, id)
+		if err != nil {
+			return http.StatusNotFound, err
+		}
+		member, err := db.IsMember(conn, id, uid)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if !member && game.HostID != uid {
+			return http.StatusForbidden, errors.New("join the game first")
+		}
+		phase, err := lifecycle.Sync(conn, game)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if err = lifecycle.Check(phase, lifecycle.Revealed, lifecycle.Archived); err != nil {
+			return http.StatusForbidden, errors.New("results are not revealed yet")
+		}
+		games = append(games, game)
+	}
+
+	sim, err := results.Similarities(games)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(sim)
+	return http.StatusOK, nil
+}
@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/lifecycle"
+	"github.com/charliekim2/songsleuths/rules"
 	"github.com/charliekim2/songsleuths/utils"
+	"gorm.io/gorm"
 )
 
 func Handler(w http.ResponseWriter, r *http.Request) {
@@ -30,6 +32,7 @@ type Submission struct {
 	Nickname string   `json:"nickname"`
 	Songs    []string `json:"songs"`
 	Drawing  string   `json:"drawing"`
+	TeamID   *uint    `json:"team_id,omitempty"` // Required when the game has teams
 }
 
 func post(w http.ResponseWriter, r *http.Request) (int, error) {
@@ -48,34 +51,57 @@ func post(w http.ResponseWriter, r *http.Request) (int, error) {
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	member, err := db.IsMember(conn, gid, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !member {
+		return http.StatusForbidden, errors.New("join the game first")
+	}
 
 	game := &db.Game{}
-	err = conn.First(game, "id = ?", gid).Error
+	err = conn.Preload("Rules").First(game, "id = ?", gid).Error
 	if err != nil {
 		return http.StatusNotFound, err
 	}
-	if time.Now().Unix() > int64(game.Deadline) {
-		return http.StatusBadRequest, errors.New("deadline has passed")
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err = lifecycle.Check(phase, lifecycle.Open); err != nil {
+		return http.StatusBadRequest, err
 	}
-	if len(submission.Songs) != int(game.NSongs) {
-		return http.StatusBadRequest, errors.New(fmt.Sprintf("number of songs should be %d", game.NSongs))
+	if len(submission.Songs) < int(game.MinSongs) || len(submission.Songs) > int(game.NSongs) {
+		if game.MinSongs == game.NSongs {
+			return http.StatusBadRequest, errors.New(fmt.Sprintf("number of songs should be %d", game.NSongs))
+		}
+		return http.StatusBadRequest, errors.New(fmt.Sprintf("number of songs should be between %d and %d", game.MinSongs, game.NSongs))
 	}
 
-	err = conn.Unscoped().Where("player_id = ? and game_id = ?", uid, gid).Delete(&db.Submission{}).Error
+	// Check the songs against the game's rules before replacing the old submission
+	tracks, err := utils.GetTracks(submission.Songs)
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	if err = rules.Check(game.Rules, tracks); err != nil {
+		return http.StatusBadRequest, err
+	}
 
-	var songs []db.Song
-	covers, err := utils.GetAlbumArt(submission.Songs)
+	// Only the live submission is replaced. An archived one keeps its suffixed
+	// keys alongside the new one, so it can still be restored in its place.
+	err = conn.Unscoped().
+		Where("player_id = ? and game_id = ? and round = ? and deleted_at IS NULL", uid, gid, game.CurrentRound).
+		Delete(&db.Submission{}).Error
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
-	for _, cover := range covers {
+
+	var songs []db.Song
+	for _, track := range tracks {
 		songs = append(songs, db.Song{
-			Spotify:  cover.ID,
-			AlbumArt: cover.URL,
-			Name:     cover.Name,
+			Spotify:  track.ID,
+			AlbumArt: track.URL,
+			Name:     track.Name,
 			GameID:   gid,
 		})
 	}
@@ -85,10 +111,12 @@ func post(w http.ResponseWriter, r *http.Request) (int, error) {
 		Nickname: submission.Nickname,
 		Songs:    songs,
 		Drawing:  submission.Drawing,
+		TeamID:   submission.TeamID,
+		Round:    game.CurrentRound,
 	}
 	err = conn.Create(&sub).Error
 	if err != nil {
-		return http.StatusInternalServerError, err
+		return http.StatusBadRequest, err
 	}
 
 	w.WriteHeader(http.StatusCreated)
@@ -106,17 +134,43 @@ func remove(w http.ResponseWriter, r *http.Request) (int, error) {
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	member, err := db.IsMember(conn, gid, uid)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if !member {
+		return http.StatusForbidden, errors.New("join the game first")
+	}
 
 	game := &db.Game{}
 	err = conn.First(game, "id = ?", gid).Error
 	if err != nil {
 		return http.StatusNotFound, err
 	}
-	if time.Now().Unix() > int64(game.Deadline) {
-		return http.StatusBadRequest, errors.New("deadline has passed")
+	phase, err := lifecycle.Sync(conn, game)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// The host can remove anyone's submission until songs reach the playlist
+	player := uid
+	allowed := []lifecycle.Phase{lifecycle.Open}
+	if other := r.URL.Query().Get("player"); other != "" && other != uid {
+		if game.HostID != uid {
+			return http.StatusForbidden, errors.New("only the host can remove other submissions")
+		}
+		player = other
+		allowed = append(allowed, lifecycle.Locked)
+	}
+	if err = lifecycle.Check(phase, allowed...); err != nil {
+		return http.StatusBadRequest, err
 	}
 
-	err = conn.Unscoped().Where("player_id = ? and game_id = ?", uid, gid).Delete(&db.Submission{}).Error
+	// Archived so a mistaken removal can be restored
+	err = db.ArchiveSubmission(conn, gid, player, game.CurrentRound)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound, errors.New("no submission to remove")
+	}
 	if err != nil {
 		return http.StatusInternalServerError, err
 	}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Archived games and submissions can be restored for this long before Purge
+// removes them for good
+const Retention = 30 * 24 * time.Hour
+
+// ArchiveSubmission soft deletes a player's submission to a round along with its songs
+// and guess tier. Its unique keys are suffixed so the player, nickname and
+// songs are free to be used again while it is archived.
+func ArchiveSubmission(conn *gorm.DB, gameID, playerID string, round uint) error {
+	return conn.Transaction(func(tx *gorm.DB) error {
+		sub := &Submission{}
+		err := tx.Where("game_id = ? AND player_id = ? AND round = ?", gameID, playerID, round).First(sub).Error
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		suffix := fmt.Sprintf("#%d", sub.ID)
+
+		err = tx.Model(&Song{}).Where("submission_id = ?", sub.ID).Updates(map[string]any{
+			"unique_song": gorm.Expr("unique_song || ?", suffix),
+			"deleted_at":  now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("submission_id = ?", sub.ID).Delete(&Tier{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Model(sub).Updates(map[string]any{
+			"unique_submission": sub.UniqueSubmission + suffix,
+			"unique_nickname":   sub.UniqueNickname + suffix,
+			"deleted_at":        now,
+		}).Error
+	})
+}
+
+// RestoreSubmission brings back a player's most recently archived submission
+// to a round.
+// It fails if the player has submitted again, or another player has since
+// taken the nickname or one of the songs.
+func RestoreSubmission(conn *gorm.DB, gameID, playerID string, round uint) error {
+	return conn.Transaction(func(tx *gorm.DB) error {
+		sub := &Submission{}
+		err := tx.Unscoped().
+			Where("game_id = ? AND player_id = ? AND round = ? AND deleted_at IS NOT NULL", gameID, playerID, round).
+			Order("deleted_at DESC").
+			Preload("Songs", func(db *gorm.DB) *gorm.DB { return db.Unscoped() }).
+			First(sub).Error
+		if err != nil {
+			return err
+		}
+
+		submission, nickname := sub.UniqueKeys()
+		err = tx.Unscoped().Model(sub).Updates(map[string]any{
+			"unique_submission": submission,
+			"unique_nickname":   nickname,
+			"deleted_at":        nil,
+		}).Error
+		if err != nil {
+			return errors.New("player has submitted again or the nickname was taken")
+		}
+		for _, song := range sub.Songs {
+			err = tx.Unscoped().Model(&song).Updates(map[string]any{
+				"unique_song": fmt.Sprintf("%s-%s", song.GameID, song.Spotify),
+				"deleted_at":  nil,
+			}).Error
+			if err != nil {
+				return fmt.Errorf("%q has since been submitted by another player", song.Name)
+			}
+		}
+		return tx.Unscoped().Model(&Tier{}).
+			Where("submission_id = ?", sub.ID).
+			Update("deleted_at", nil).Error
+	})
+}
+
+// ArchivedBefore lists the games archived before the cutoff
+func ArchivedBefore(conn *gorm.DB, cutoff time.Time) ([]Game, error) {
+	games := []Game{}
+	err := conn.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Find(&games).Error
+	return games, err
+}
+
+// PurgeGame permanently deletes a game and everything in it
+func PurgeGame(conn *gorm.DB, gameID string) error {
+	return conn.Unscoped().Select(clause.Associations).Delete(&Game{ID: gameID}).Error
+}
+
+// PurgeSubmissions permanently deletes submissions archived before the
+// cutoff, with their songs and guess tiers
+func PurgeSubmissions(conn *gorm.DB, cutoff time.Time) error {
+	return conn.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Delete(&Submission{}).Error
+}
@@ -0,0 +1,39 @@
+package db
+
+import (
+	"os"
+	"strconv"
+)
+
+// Server-wide caps games are configured within, overridable by env for
+// deployments that want bigger games
+const (
+	defaultMaxSongs   = 5
+	defaultMaxPlayers = 50
+)
+
+// MaxSongs is the most songs any game can ask each player for
+func MaxSongs() uint {
+	return envLimit("MAX_SONGS", defaultMaxSongs)
+}
+
+// MaxPlayers is the most players any game can hold
+func MaxPlayers() uint {
+	return envLimit("MAX_PLAYERS", defaultMaxPlayers)
+}
+
+// PlayerLimit is the most players a game can hold, its own cap if it has one
+func (g *Game) PlayerLimit() uint {
+	if g.MaxPlayers == 0 {
+		return MaxPlayers()
+	}
+	return g.MaxPlayers
+}
+
+func envLimit(key string, fallback uint) uint {
+	n, err := strconv.ParseUint(os.Getenv(key), 10, 0)
+	if err != nil || n == 0 {
+		return fallback
+	}
+	return uint(n)
+}
@@ -0,0 +1,59 @@
+package db
+
+import "gorm.io/gorm"
+
+// AddMember records a player as part of a game in player_games. Adding an
+// existing member does nothing.
+func AddMember(conn *gorm.DB, gameID, playerID string) error {
+	return conn.Model(&Game{ID: gameID}).Association("Players").Append(&Player{ID: playerID})
+}
+
+// IsMember reports whether a player has joined a game
+func IsMember(conn *gorm.DB, gameID, playerID string) (bool, error) {
+	var count int64
+	err := conn.Table("player_games").
+		Where("game_id = ? AND player_id = ?", gameID, playerID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// CountMembers returns how many players have joined a game
+func CountMembers(conn *gorm.DB, gameID string) (int64, error) {
+	var count int64
+	err := conn.Table("player_games").Where("game_id = ?", gameID).Count(&count).Error
+	return count, err
+}
+
+// CoPlayers queries the ids of everyone who has joined a game with a player,
+// the player included
+func CoPlayers(conn *gorm.DB, playerID string) *gorm.DB {
+	return conn.Table("player_games").
+		Distinct("player_id").
+		Where("game_id IN (?)", conn.Table("player_games").Select("game_id").Where("player_id = ?", playerID))
+}
+
+// SharesGame reports whether two players have joined any game together
+func SharesGame(conn *gorm.DB, playerID, otherID string) (bool, error) {
+	if playerID == otherID {
+		return true, nil
+	}
+	var count int64
+	err := conn.Table("player_games AS mine").
+		Joins("JOIN player_games AS theirs ON theirs.game_id = mine.game_id").
+		Where("mine.player_id = ? AND theirs.player_id = ?", playerID, otherID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// IsLeagueMember reports whether a player owns a league or has joined one of
+// its games
+func IsLeagueMember(conn *gorm.DB, league *League, playerID string) (bool, error) {
+	if league.OwnerID == playerID {
+		return true, nil
+	}
+	var count int64
+	err := conn.Table("player_games").
+		Where("game_id IN (?) AND player_id = ?", conn.Model(&Game{}).Select("id").Where("league_id = ?", league.ID), playerID).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -13,6 +13,10 @@ type Player struct {
 	ID    string  `gorm:"primarykey"` // Firebase UID
 	Games []*Game `gorm:"many2many:player_games;"`
 
+	// Elo rating from guessing, updated when a game's results are revealed
+	Rating     float64 `gorm:"not null;default:1500"`
+	RatedGames uint    `gorm:"not null;default:0"`
+
 	// One-to-many relationships
 	Submissions []Submission `gorm:"constraint:OnDelete:CASCADE;"`
 	Rankings    []Ranking    `gorm:"constraint:OnDelete:CASCADE;"`
@@ -27,6 +31,42 @@ type Game struct {
 	Playlist   string    `gorm:"not null"`
 	AddedSongs bool      `gorm:"not null"` // Were songs added to playlist yet or not
 
+	// Players submit between MinSongs and NSongs songs, defaults to exactly NSongs
+	MinSongs uint `gorm:"not null;default:0"`
+	// Submissions needed before the game can move on, 0 = no quorum
+	MinPlayers uint `gorm:"not null;default:0"`
+	// Joins are refused once the game has this many members, 0 = up to MaxPlayers()
+	MaxPlayers uint `gorm:"not null;default:0"`
+
+	// Secret players use to join the game
+	InviteToken string `gorm:"index"`
+	// Firebase UID of the player who created and administers the game
+	HostID string `gorm:"index"`
+
+	// Where the game is in its lifecycle, see lifecycle.Phase
+	Phase string `gorm:"not null;default:open"`
+	// Rankings close and results are revealed at this time, 0 = once everyone has ranked
+	RankingDeadline uint
+
+	// Name of the scoring strategy used for guesses, see results.Strategies
+	Scoring string `gorm:"not null;default:flat"`
+	// How songs are ranked: "tierlist" (named tiers) or "pairwise" (head-to-head votes)
+	RankingMode string `gorm:"not null;default:tierlist"`
+	// Names of the ranking tiers to create, best first. Defaults to S-D.
+	Tiers []string `gorm:"-"`
+
+	// Theme shown to players while they pick songs
+	Prompt string
+
+	// Rounds of the game in order. Deadline, RankingDeadline, NSongs,
+	// MinSongs and Prompt hold the current round's settings, copied from
+	// here when the game moves on to the next round.
+	Rounds       []Round `gorm:"constraint:OnDelete:CASCADE;"`
+	NRounds      uint    `gorm:"not null;default:1"`
+	CurrentRound uint    `gorm:"not null;default:1"`
+	// Machine-checked constraints on submitted songs, see rules.Kinds
+	Rules []Rule `gorm:"constraint:OnDelete:CASCADE;"`
+
 	// One-to-many relationships - each game has exactly two tierlists
 	Tierlists []Tierlist `gorm:"constraint:OnDelete:CASCADE;"`
 	// GuessList   Tierlist   `gorm:"foreignKey:GameID;constraint:OnDelete:CASCADE;"`
@@ -35,12 +75,66 @@ type Game struct {
 
 	// One-to-many relationship with submissions
 	Submissions []Submission `gorm:"constraint:OnDelete:CASCADE;"`
+	// Teams that pool their songs, guessed as a team instead of per player
+	Teams []Team `gorm:"constraint:OnDelete:CASCADE;"`
+	// Head-to-head votes for pairwise ranking mode
+	Votes []PairwiseVote `gorm:"constraint:OnDelete:CASCADE;"`
+	// Audit trail of the host moving the deadline
+	DeadlineChanges []DeadlineChange `gorm:"constraint:OnDelete:CASCADE;"`
+
+	// League the game counts towards, if any
+	LeagueID *string `gorm:"index"`
+	// Were the results applied to player ratings yet or not
+	Rated bool `gorm:"not null;default:false"`
+	// Finished game this one was started as a rematch of, if any
+	RematchOf *string `gorm:"index"`
+	// Set when the host archives the game, see Retention
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+type League struct {
+	ID      string `gorm:"primarykey"`
+	Name    string `gorm:"not null"`
+	OwnerID string `gorm:"not null"` // Firebase UID of the player who created the league
+
+	// One-to-many relationship, games stay when their league is deleted
+	Games []Game `gorm:"constraint:OnDelete:SET NULL;"`
+}
+
+type RatingChange struct {
+	gorm.Model
+	PlayerID string  `gorm:"not null;index"`
+	GameID   string  `gorm:"not null"`
+	Before   float64 `gorm:"not null"`
+	After    float64 `gorm:"not null"`
+}
+
+type Round struct {
+	gorm.Model
+	GameID          string `gorm:"not null;index"`
+	Number          uint   `gorm:"not null"` // 1 = first round
+	Prompt          string
+	Deadline        uint `gorm:"not null"`
+	RankingDeadline uint
+	NSongs          uint `gorm:"not null"`
+	MinSongs        uint `gorm:"not null"`
+}
+
+type Team struct {
+	gorm.Model
+	GameID string `gorm:"not null;index"`
+	Name   string `gorm:"not null"`
+
+	// The team's tier in the guess list
+	Tier        Tier         `gorm:"constraint:OnDelete:CASCADE;"`
+	Submissions []Submission `gorm:"constraint:OnDelete:SET NULL;"`
 }
 
 type Tierlist struct {
 	gorm.Model
 	GameID string `gorm:"not null"`
 	Type   string `gorm:"not null"` // "guess" or "ranking"
+	Round  uint   `gorm:"not null;default:1"`
 
 	// One-to-many relationships
 	Tiers    []Tier    `gorm:"constraint:OnDelete:CASCADE;"`
@@ -53,6 +147,7 @@ type Tier struct {
 	Rank         int    `gorm:"not null"` // Lower number = higher rank
 	TierlistID   uint   `gorm:"not null"`
 	SubmissionID *uint  // For guess list, associate player tier w/ their submission
+	TeamID       *uint  // For guess list in teams mode, associate team tier w/ the team
 }
 
 type Submission struct {
@@ -63,6 +158,8 @@ type Submission struct {
 	Songs    []Song `gorm:"constraint:OnDelete:CASCADE;"`
 	Drawing  string `gorm:"not null"`
 	Tier     Tier   `gorm:"constraint:OnDelete:CASCADE;"`
+	TeamID   *uint  `gorm:"index"` // Team the player's songs are pooled with, if the game has teams
+	Round    uint   `gorm:"not null;default:1"`
 
 	// Unique constraint to ensure one submission per player per game
 	UniqueSubmission string `gorm:"uniqueIndex:idx_player_game"`
@@ -94,52 +191,354 @@ type Ranking struct {
 	// Foreign key constraints
 	Player   Player   `gorm:"constraint:OnDelete:CASCADE;"`
 	Tierlist Tierlist `gorm:"constraint:OnDelete:CASCADE;"`
+
+	// Every version of this ranking, oldest first
+	Revisions []RankingRevision `gorm:"constraint:OnDelete:CASCADE;"`
 }
 
+type RankingRevision struct {
+	gorm.Model
+	RankingID  uint   `gorm:"not null;index"`
+	PlayerID   string `gorm:"not null"`
+	TierlistID uint   `gorm:"not null"`
+	GameID     string `gorm:"not null;index"`
+	Ranking    string `gorm:"not null"` // JSON tier: []songs as submitted at CreatedAt
+}
+
+type Rule struct {
+	gorm.Model
+	GameID string `gorm:"not null;index"`
+	Kind   string `gorm:"not null"`
+	Value  string // Year, number of seconds or artist name depending on Kind
+}
+
+type DeadlineChange struct {
+	gorm.Model
+	GameID   string `gorm:"not null;index"`
+	PlayerID string `gorm:"not null"` // Who moved the deadline
+	Before   uint   `gorm:"not null"`
+	After    uint   `gorm:"not null"`
+}
+
+type PairwiseVote struct {
+	gorm.Model
+	GameID   string `gorm:"not null;index"`
+	PlayerID string `gorm:"not null"`
+	WinnerID uint   `gorm:"not null"` // Song the player preferred
+	LoserID  uint   `gorm:"not null"`
+}
+
+// Host-added decoy songs are submitted by this placeholder player, so they
+// get a guess tier of their own like any other submission
+const (
+	DecoyPlayer   = "decoy"
+	DecoyNickname = "Nobody"
+	MaxDecoys     = 10
+)
+
 // Hooks to enforce business rules
 
 func (g *Game) BeforeCreate(tx *gorm.DB) error {
-	// If Deadline is before the current time, return an error
-	if g.Deadline < uint(time.Now().Unix()) {
-		return errors.New("deadline must be in the future")
+	if err := ValidateDeadline(g.Deadline, g.RankingDeadline); err != nil {
+		return err
+	}
+	if err := ValidateName(g.Name); err != nil {
+		return err
+	}
+	if g.MinSongs == 0 {
+		g.MinSongs = g.NSongs
+	}
+	if err := ValidateSongRange(g.MinSongs, g.NSongs); err != nil {
+		return err
+	}
+	if err := ValidatePlayers(g.MinPlayers, g.MaxPlayers); err != nil {
+		return err
+	}
+	if err := ValidatePrompt(g.Prompt); err != nil {
+		return err
+	}
+	if len(g.Teams) > 0 {
+		names := []string{}
+		for _, t := range g.Teams {
+			names = append(names, t.Name)
+		}
+		if err := ValidateTeams(names); err != nil {
+			return err
+		}
+	}
+	if len(g.Tiers) == 0 {
+		g.Tiers = DefaultTiers
+	}
+	// The game's own settings are the first round, any rounds given follow it
+	first := Round{
+		Prompt:          g.Prompt,
+		Deadline:        g.Deadline,
+		RankingDeadline: g.RankingDeadline,
+		NSongs:          g.NSongs,
+		MinSongs:        g.MinSongs,
+	}
+	g.Rounds = append([]Round{first}, g.Rounds...)
+	for i := range g.Rounds {
+		g.Rounds[i].Number = uint(i + 1)
+		if g.Rounds[i].MinSongs == 0 {
+			g.Rounds[i].MinSongs = g.Rounds[i].NSongs
+		}
+	}
+	if err := ValidateRounds(g.Rounds); err != nil {
+		return err
+	}
+	g.NRounds = uint(len(g.Rounds))
+	g.CurrentRound = 1
+	if err := ValidateTiers(g.Tiers); err != nil {
+		return err
 	}
 
-	// Name must be between 1 and 50 characters
-	if len(g.Name) < 1 || len(g.Name) > 50 {
-		return errors.New("name must be between 1 and 50 characters")
+	id, err := gonanoid.New()
+	if err != nil {
+		return err
 	}
 
-	// Num songs must be between 1 and 5
-	if g.NSongs < 1 || g.NSongs > 5 {
-		return errors.New("number of songs must be between 1 and 5")
+	if g.RankingMode == "" {
+		g.RankingMode = "tierlist"
+	}
+	if g.RankingMode != "tierlist" && g.RankingMode != "pairwise" {
+		return errors.New("ranking mode must be tierlist or pairwise")
 	}
 
-	id, err := gonanoid.New()
+	token, err := gonanoid.New()
 	if err != nil {
 		return err
 	}
 
 	g.ID = id
+	g.InviteToken = token
 	g.AddedSongs = false
-	g.Tierlists = append(g.Tierlists, Tierlist{Type: "guess"})
-	ranking := Tierlist{Type: "ranking"}
-	// Populate RankingList with default tiers (S, A, B, C, D)
-	for i, tier := range []string{"S", "A", "B", "C", "D"} {
-		ranking.Tiers = append(ranking.Tiers, Tier{Name: tier, Rank: i})
+	if g.Phase == "" {
+		g.Phase = "open"
+	}
+	// Each round has its own tierlists
+	for _, round := range g.Rounds {
+		g.Tierlists = append(g.Tierlists, Tierlist{Type: "guess", Round: round.Number})
+		// Pairwise games rank songs with votes instead of a tierlist
+		if g.RankingMode == "tierlist" {
+			ranking := Tierlist{Type: "ranking", Round: round.Number}
+			for i, tier := range g.Tiers {
+				ranking.Tiers = append(ranking.Tiers, Tier{Name: tier, Rank: i})
+			}
+			g.Tierlists = append(g.Tierlists, ranking)
+		}
+	}
+
+	return nil
+}
+
+// Team tiers can only be made once the guess lists and teams have ids
+func (g *Game) AfterCreate(tx *gorm.DB) error {
+	if len(g.Teams) == 0 {
+		return nil
 	}
-	g.Tierlists = append(g.Tierlists, ranking)
+	for _, list := range g.Tierlists {
+		if list.Type != "guess" {
+			continue
+		}
+		for i := range g.Teams {
+			tier := Tier{
+				Name:       g.Teams[i].Name,
+				Rank:       0,
+				TierlistID: list.ID,
+				TeamID:     &g.Teams[i].ID,
+			}
+			if err := tx.Create(&tier).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
 
+// Deadline must be in the future, and before the ranking deadline if there is one
+func ValidateDeadline(deadline, rankingDeadline uint) error {
+	if deadline < uint(time.Now().Unix()) {
+		return errors.New("deadline must be in the future")
+	}
+	if rankingDeadline != 0 && rankingDeadline <= deadline {
+		return errors.New("ranking deadline must be after the deadline")
+	}
 	return nil
 }
 
+// At most 10 rounds, each valid on its own and starting after the previous
+// round's deadlines
+func ValidateRounds(rounds []Round) error {
+	if len(rounds) > 10 {
+		return errors.New("a game can have at most 10 rounds")
+	}
+	var end uint
+	for _, r := range rounds {
+		if err := ValidateDeadline(r.Deadline, r.RankingDeadline); err != nil {
+			return fmt.Errorf("round %d: %w", r.Number, err)
+		}
+		if r.Deadline <= end {
+			return fmt.Errorf("round %d: deadline must be after the previous round's", r.Number)
+		}
+		if err := ValidateSongRange(r.MinSongs, r.NSongs); err != nil {
+			return fmt.Errorf("round %d: %w", r.Number, err)
+		}
+		if err := ValidatePrompt(r.Prompt); err != nil {
+			return fmt.Errorf("round %d: %w", r.Number, err)
+		}
+		end = max(r.Deadline, r.RankingDeadline)
+	}
+	return nil
+}
+
+// Ranking tiers for games that don't name their own
+var DefaultTiers = []string{"S", "A", "B", "C", "D"}
+
+// Between 2 and 10 tiers, each with a unique name of 1 to 20 characters
+func ValidateTiers(tiers []string) error {
+	if len(tiers) < 2 || len(tiers) > 10 {
+		return errors.New("number of tiers must be between 2 and 10")
+	}
+	seen := make(map[string]bool)
+	for _, tier := range tiers {
+		if len(tier) < 1 || len(tier) > 20 {
+			return errors.New("tier names must be between 1 and 20 characters")
+		}
+		if seen[tier] {
+			return errors.New("tier names must be unique")
+		}
+		seen[tier] = true
+	}
+	return nil
+}
+
+// Name must be between 1 and 50 characters
+func ValidateName(name string) error {
+	if len(name) < 1 || len(name) > 50 {
+		return errors.New("name must be between 1 and 50 characters")
+	}
+	return nil
+}
+
+// Between 2 and 10 teams, each with a unique name of 1 to 20 characters
+func ValidateTeams(teams []string) error {
+	if len(teams) < 2 || len(teams) > 10 {
+		return errors.New("number of teams must be between 2 and 10")
+	}
+	seen := make(map[string]bool)
+	for _, team := range teams {
+		if len(team) < 1 || len(team) > 20 {
+			return errors.New("team names must be between 1 and 20 characters")
+		}
+		if seen[team] {
+			return errors.New("team names must be unique")
+		}
+		seen[team] = true
+	}
+	return nil
+}
+
+// Prompt is optional, but at most 200 characters
+func ValidatePrompt(prompt string) error {
+	if len(prompt) > 200 {
+		return errors.New("prompt must be at most 200 characters")
+	}
+	return nil
+}
+
+// Num songs must be between 1 and MaxSongs()
+func ValidateNSongs(n uint) error {
+	if n < 1 || n > MaxSongs() {
+		return fmt.Errorf("number of songs must be between 1 and %d", MaxSongs())
+	}
+	return nil
+}
+
+// Min songs must be at least 1 and no more than the number of songs
+func ValidateSongRange(min, n uint) error {
+	if err := ValidateNSongs(n); err != nil {
+		return err
+	}
+	if min < 1 || min > n {
+		return fmt.Errorf("minimum number of songs must be between 1 and %d", n)
+	}
+	return nil
+}
+
+// Player counts are optional, but can't exceed MaxPlayers() or each other
+func ValidatePlayers(min, max uint) error {
+	if min > MaxPlayers() || max > MaxPlayers() {
+		return fmt.Errorf("number of players must be at most %d", MaxPlayers())
+	}
+	if max != 0 && min > max {
+		return errors.New("minimum players must not be more than maximum players")
+	}
+	return nil
+}
+
+func (l *League) BeforeCreate(tx *gorm.DB) error {
+	if err := ValidateName(l.Name); err != nil {
+		return err
+	}
+
+	id, err := gonanoid.New()
+	if err != nil {
+		return err
+	}
+	l.ID = id
+
+	return nil
+}
+
+// Unique constraint values for a submission. Rounds after the first are
+// suffixed so players can submit once per round.
+func (s *Submission) UniqueKeys() (string, string) {
+	submission := fmt.Sprintf("%s-%s", s.PlayerID, s.GameID)
+	nickname := fmt.Sprintf("%s-%s", s.Nickname, s.GameID)
+	if s.Round > 1 {
+		submission += fmt.Sprintf("-%d", s.Round)
+		nickname += fmt.Sprintf("-%d", s.Round)
+	}
+	return submission, nickname
+}
+
 func (s *Submission) BeforeCreate(tx *gorm.DB) error {
+	if s.Round == 0 {
+		s.Round = 1
+	}
 	// Set the unique constraint value
-	s.UniqueSubmission = fmt.Sprintf("%s-%s", s.PlayerID, s.GameID)
-	s.UniqueNickname = fmt.Sprintf("%s-%s", s.Nickname, s.GameID)
+	s.UniqueSubmission, s.UniqueNickname = s.UniqueKeys()
+	if s.PlayerID != DecoyPlayer && s.Nickname == DecoyNickname {
+		return errors.New("nickname " + DecoyNickname + " is reserved for decoys")
+	}
+
+	// Games with teams guess by team, so players must be on one of them.
+	// Decoys belong to no team.
+	var teams int64
+	if err := tx.Model(&Team{}).Where("game_id = ?", s.GameID).Count(&teams).Error; err != nil {
+		return err
+	}
+	if teams > 0 && s.PlayerID != DecoyPlayer {
+		if s.TeamID == nil {
+			return errors.New("pick a team to submit for")
+		}
+		var team Team
+		if err := tx.First(&team, *s.TeamID).Error; err != nil {
+			return err
+		}
+		if team.GameID != s.GameID {
+			return errors.New("team does not belong to game")
+		}
+		return nil
+	}
+	if s.TeamID != nil {
+		return errors.New("game does not have teams")
+	}
 
 	// Create tier in guesslist associated with submission
 	var tierlist Tierlist
-	err := tx.Where("type = ? and game_id = ?", "guess", s.GameID).First(&tierlist).Error
+	err := tx.Where("type = ? and game_id = ? and round = ?", "guess", s.GameID, s.Round).First(&tierlist).Error
 	if err != nil {
 		return err
 	}
@@ -212,3 +611,33 @@ func (r *Ranking) BeforeCreate(tx *gorm.DB) error {
 
 	return nil
 }
+
+func (v *PairwiseVote) BeforeCreate(tx *gorm.DB) error {
+	if v.WinnerID == v.LoserID {
+		return errors.New("a song cannot be voted against itself")
+	}
+
+	// Both songs must be part of the game
+	var count int64
+	if err := tx.Model(&Song{}).
+		Where("game_id = ? AND id IN ?", v.GameID, []uint{v.WinnerID, v.LoserID}).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count != 2 {
+		return errors.New("songs do not belong to game")
+	}
+
+	return nil
+}
+
+func (r *Ranking) AfterSave(tx *gorm.DB) error {
+	// Keep a copy of every version of the ranking
+	return tx.Create(&RankingRevision{
+		RankingID:  r.ID,
+		PlayerID:   r.PlayerID,
+		TierlistID: r.TierlistID,
+		GameID:     r.GameID,
+		Ranking:    r.Ranking,
+	}).Error
+}
@@ -0,0 +1,342 @@
+package lifecycle
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/results"
+	"github.com/charliekim2/songsleuths/utils"
+	"gorm.io/gorm"
+)
+
+type Phase string
+
+const (
+	Draft    Phase = "draft"    // Created but not accepting submissions yet
+	Open     Phase = "open"     // Accepting submissions
+	Locked   Phase = "locked"   // Deadline passed, songs not yet added to the playlist
+	Ranking  Phase = "ranking"  // Players are guessing and ranking songs
+	Revealed Phase = "revealed" // Ranking deadline passed or everyone ranked, results are visible
+	Archived Phase = "archived" // Deleted by the host, restorable until purged
+)
+
+// Allowed moves between phases
+var transitions = map[Phase][]Phase{
+	Draft:    {Open, Archived},
+	Open:     {Locked, Archived},
+	Locked:   {Open, Ranking, Archived}, // Back to open if the deadline is extended
+	Ranking:  {Revealed, Archived},
+	Revealed: {Archived},
+	Archived: {},
+}
+
+func CanTransition(from, to Phase) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// Current works out which phase a game is in at the given time, advancing
+// past any phases whose end condition has already been met. Games short of
+// their quorum of submitted players stay open past the deadline.
+func Current(game *db.Game, submitted int64, now time.Time) Phase {
+	// Archiving keeps the stored phase so restoring picks up where it left off
+	if game.DeletedAt.Valid {
+		return Archived
+	}
+	phase := Phase(game.Phase)
+	for {
+		next := phase
+		switch phase {
+		case Open:
+			if now.Unix() > int64(game.Deadline) && submitted >= int64(game.MinPlayers) {
+				next = Locked
+			}
+		case Locked:
+			if game.AddedSongs {
+				next = Ranking
+			}
+		case Ranking:
+			if game.RankingDeadline != 0 && now.Unix() > int64(game.RankingDeadline) {
+				next = Revealed
+			}
+		}
+		if next == phase {
+			return phase
+		}
+		phase = next
+	}
+}
+
+// Sync stores the game's current phase if time has moved it on
+func Sync(conn *gorm.DB, game *db.Game) (Phase, error) {
+	if game.DeletedAt.Valid {
+		return Archived, nil
+	}
+	var submitted int64
+	if Phase(game.Phase) == Open && game.MinPlayers > 0 {
+		if err := roundSubmissions(conn, game).Count(&submitted).Error; err != nil {
+			return "", err
+		}
+	}
+	phase := Current(game, submitted, time.Now())
+	if phase != Phase(game.Phase) {
+		err := conn.Model(&db.Game{}).
+			Where("id = ? AND phase = ?", game.ID, game.Phase).
+			Update("phase", string(phase)).Error
+		if err != nil {
+			return "", err
+		}
+		game.Phase = string(phase)
+	}
+	if err := enter(conn, game, phase); err != nil {
+		return "", err
+	}
+	// Adding the songs starts ranking, and revealing a round can start the next
+	if Phase(game.Phase) != phase {
+		return Sync(conn, game)
+	}
+	return phase, nil
+}
+
+// Transition moves the game to a new phase if the move is allowed
+func Transition(conn *gorm.DB, game *db.Game, to Phase) error {
+	from := Phase(game.Phase)
+	if !CanTransition(from, to) {
+		return fmt.Errorf("game cannot move from %s to %s", from, to)
+	}
+	res := conn.Model(&db.Game{}).
+		Where("id = ? AND phase = ?", game.ID, game.Phase).
+		Update("phase", string(to))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("game is no longer %s", from)
+	}
+	game.Phase = string(to)
+	return enter(conn, game, to)
+}
+
+// Archive moves the game to the archived phase by soft deleting it
+func Archive(conn *gorm.DB, game *db.Game) error {
+	from, err := Sync(conn, game)
+	if err != nil {
+		return err
+	}
+	if !CanTransition(from, Archived) {
+		return fmt.Errorf("game cannot move from %s to %s", from, Archived)
+	}
+	return conn.Delete(&db.Game{ID: game.ID}).Error
+}
+
+// enter runs the side effects of a game reaching a phase. It is safe to call
+// more than once for the same phase.
+func enter(conn *gorm.DB, game *db.Game, phase Phase) error {
+	if phase == Locked && !game.AddedSongs {
+		return addSongs(conn, game)
+	}
+	if phase == Revealed && game.CurrentRound < game.NRounds {
+		return nextRound(conn, game)
+	}
+	if phase == Revealed && !game.Rated {
+		if err := results.Finalize(conn, game.ID); err != nil {
+			return err
+		}
+		game.Rated = true
+	}
+	return nil
+}
+
+// addSongs adds the round's songs to the playlist, which opens it for ranking
+func addSongs(conn *gorm.DB, game *db.Game) error {
+	// Claim the job first so the songs are only added once
+	res := conn.Model(&db.Game{}).
+		Where("id = ? AND added_songs = ?", game.ID, false).
+		Update("added_songs", true)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return conn.First(game, "id = ?", game.ID).Error
+	}
+
+	// Decoys go in the playlist too
+	var songs []string
+	err := conn.Model(&db.Song{}).
+		Where("submission_id IN (?)", conn.Model(&db.Submission{}).Select("id").
+			Where("game_id = ? AND round = ?", game.ID, game.CurrentRound)).
+		Pluck("spotify", &songs).Error
+	if err == nil && len(songs) > 0 {
+		err = utils.AddToPlaylist(songs, game.Playlist)
+	}
+	if err != nil {
+		// Let the next request try again
+		conn.Model(&db.Game{}).Where("id = ?", game.ID).Update("added_songs", false)
+		return err
+	}
+	game.AddedSongs = true
+	return Transition(conn, game, Ranking)
+}
+
+// nextRound opens the round after a revealed one, copying its settings onto
+// the game. This skips the usual transitions since the game starts over.
+func nextRound(conn *gorm.DB, game *db.Game) error {
+	round := &db.Round{}
+	err := conn.Where("game_id = ? AND number = ?", game.ID, game.CurrentRound+1).First(round).Error
+	if err != nil {
+		return err
+	}
+	// A round that ran past its deadlines pushes back the rest of the game, so
+	// the next round still gets its full time for submissions
+	var shift uint
+	end := max(game.Deadline, game.RankingDeadline)
+	if now := uint(time.Now().Unix()); now > end {
+		shift = now - end
+		round.Deadline += shift
+		if round.RankingDeadline != 0 {
+			round.RankingDeadline += shift
+		}
+	}
+
+	claimed := true
+	err = conn.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&db.Game{}).
+			Where("id = ? AND phase = ? AND current_round = ?", game.ID, string(Revealed), game.CurrentRound).
+			Updates(map[string]any{
+				"current_round":    round.Number,
+				"phase":            string(Open),
+				"added_songs":      false,
+				"deadline":         round.Deadline,
+				"ranking_deadline": round.RankingDeadline,
+				"n_songs":          round.NSongs,
+				"min_songs":        round.MinSongs,
+				"prompt":           round.Prompt,
+			})
+		if res.Error != nil || res.RowsAffected == 0 {
+			claimed = false
+			return res.Error
+		}
+		if shift == 0 {
+			return nil
+		}
+		return tx.Model(&db.Round{}).
+			Where("game_id = ? AND number >= ?", game.ID, round.Number).
+			Updates(map[string]any{
+				"deadline": gorm.Expr("deadline + ?", shift),
+				"ranking_deadline": gorm.Expr(
+					"CASE WHEN ranking_deadline > 0 THEN ranking_deadline + ? ELSE 0 END", shift),
+			}).Error
+	})
+	if err != nil {
+		return err
+	}
+	// Someone else got there first, pick up where they left the game
+	if !claimed {
+		return conn.First(game, "id = ?", game.ID).Error
+	}
+	game.CurrentRound = round.Number
+	game.Phase = string(Open)
+	game.AddedSongs = false
+	game.Deadline = round.Deadline
+	game.RankingDeadline = round.RankingDeadline
+	game.NSongs = round.NSongs
+	game.MinSongs = round.MinSongs
+	game.Prompt = round.Prompt
+	return nil
+}
+
+// roundSubmissions queries the players' submissions to the game's current round
+func roundSubmissions(conn *gorm.DB, game *db.Game) *gorm.DB {
+	return conn.Model(&db.Submission{}).
+		Where("game_id = ? AND round = ? AND player_id != ?", game.ID, game.CurrentRound, db.DecoyPlayer)
+}
+
+// roundTierlists queries the tierlists of the game's current round
+func roundTierlists(conn *gorm.DB, game *db.Game) *gorm.DB {
+	return conn.Model(&db.Tierlist{}).Where("game_id = ? AND round = ?", game.ID, game.CurrentRound)
+}
+
+// Check returns a PhaseError unless phase is one of the allowed phases
+func Check(phase Phase, allowed ...Phase) error {
+	if !slices.Contains(allowed, phase) {
+		return &PhaseError{Phase: phase}
+	}
+	return nil
+}
+
+type PhaseError struct {
+	Phase Phase
+}
+
+func (e *PhaseError) Error() string {
+	switch e.Phase {
+	case Draft:
+		return "game is not open yet"
+	case Open:
+		return "game is still accepting submissions"
+	case Locked, Ranking:
+		return "deadline has passed"
+	case Revealed:
+		return "ranking has closed"
+	case Archived:
+		return "game has been archived"
+	}
+	return fmt.Sprintf("game is %s", e.Phase)
+}
+
+// HasQuorum reports whether enough players have submitted for the game to
+// move on from submissions
+func HasQuorum(conn *gorm.DB, game *db.Game) (bool, error) {
+	var submitted int64
+	if err := roundSubmissions(conn, game).Count(&submitted).Error; err != nil {
+		return false, err
+	}
+	return submitted >= int64(game.MinPlayers), nil
+}
+
+// RevealWhenRanked reveals the round's results once every player who
+// submitted songs to it has ranked every tierlist in it, and in pairwise
+// games voted at least once
+func RevealWhenRanked(conn *gorm.DB, game *db.Game) error {
+	if Phase(game.Phase) != Ranking {
+		return nil
+	}
+	var submitters, tierlists, rankings int64
+	if err := roundSubmissions(conn, game).Count(&submitters).Error; err != nil {
+		return err
+	}
+	if err := roundTierlists(conn, game).Count(&tierlists).Error; err != nil {
+		return err
+	}
+	err := conn.Model(&db.Ranking{}).
+		Where("tierlist_id IN (?) AND player_id IN (?)",
+			roundTierlists(conn, game).Select("id"),
+			roundSubmissions(conn, game).Select("player_id")).
+		Count(&rankings).Error
+	if err != nil {
+		return err
+	}
+	if submitters == 0 || rankings < submitters*tierlists {
+		return nil
+	}
+	// Pairwise games only have a guess list, so votes count as the ranking
+	if game.RankingMode == "pairwise" {
+		var voters int64
+		songs := conn.Model(&db.Song{}).Select("id").
+			Where("submission_id IN (?)", conn.Model(&db.Submission{}).Select("id").
+				Where("game_id = ? AND round = ?", game.ID, game.CurrentRound))
+		err := conn.Model(&db.PairwiseVote{}).
+			Where("game_id = ? AND winner_id IN (?) AND player_id IN (?)",
+				game.ID, songs, roundSubmissions(conn, game).Select("player_id")).
+			Distinct("player_id").
+			Count(&voters).Error
+		if err != nil {
+			return err
+		}
+		if voters < submitters {
+			return nil
+		}
+	}
+	return Transition(conn, game, Revealed)
+}
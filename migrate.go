@@ -3,6 +3,8 @@ package main
 import (
 	"github.com/charliekim2/songsleuths/db"
 	"github.com/joho/godotenv"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"gorm.io/gorm"
 )
 
 func main() {
@@ -17,6 +19,7 @@ func main() {
 	}
 
 	conn.AutoMigrate(
+		&db.League{},
 		&db.Game{},
 		&db.Player{},
 		&db.Tierlist{},
@@ -24,5 +27,41 @@ func main() {
 		&db.Submission{},
 		&db.Song{},
 		&db.Ranking{},
+		&db.RankingRevision{},
+		&db.PairwiseVote{},
+		&db.RatingChange{},
+		&db.DeadlineChange{},
+		&db.Rule{},
+		&db.Team{},
+		&db.Round{},
 	)
+
+	// Decoy submissions need a player to belong to
+	if err = conn.FirstOrCreate(&db.Player{ID: db.DecoyPlayer}).Error; err != nil {
+		panic(err)
+	}
+
+	// Games from before invites need a token, and their submitters as members
+	var games []db.Game
+	if err = conn.Where("invite_token = '' OR invite_token IS NULL").Find(&games).Error; err != nil {
+		panic(err)
+	}
+	for _, g := range games {
+		token, err := gonanoid.New()
+		if err != nil {
+			panic(err)
+		}
+		if err = conn.Model(&db.Game{ID: g.ID}).Update("invite_token", token).Error; err != nil {
+			panic(err)
+		}
+	}
+	// Games from before song ranges ask for exactly NSongs
+	err = conn.Model(&db.Game{}).Where("min_songs = 0").Update("min_songs", gorm.Expr("n_songs")).Error
+	if err != nil {
+		panic(err)
+	}
+	err = conn.Exec("INSERT OR IGNORE INTO player_games (game_id, player_id) SELECT DISTINCT game_id, player_id FROM submissions").Error
+	if err != nil {
+		panic(err)
+	}
 }
@@ -0,0 +1,210 @@
+package recap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/charliekim2/songsleuths/results"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	width     = 800
+	padding   = 32
+	thumbSize = 64
+	rowHeight = thumbSize + 16
+	podium    = 3 // Top guessers shown
+	topSongs  = 5
+
+	// Images are only ever drawn as thumbnails, anything bigger than this is
+	// refused before it is decoded
+	maxPixels = 1024 * 1024
+	maxBytes  = 4 << 20
+)
+
+var (
+	background = color.RGBA{0x1f, 0x29, 0x37, 0xff} // Matches the tierlist's gray-800
+	panel      = color.RGBA{0x37, 0x41, 0x51, 0xff}
+	white      = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	muted      = color.RGBA{0x9c, 0xa3, 0xaf, 0xff}
+	accent     = color.RGBA{0xa8, 0x55, 0xf7, 0xff} // Purple used for highlights
+)
+
+type Sleuth struct {
+	Nickname string
+	Points   float64
+	Drawing  string // Data URL of the player's drawing, may be empty
+}
+
+type Song struct {
+	Name     string
+	Nickname string // Who submitted it
+	AlbumArt string // URL of the cover art, may be empty
+}
+
+type Recap struct {
+	Game    string
+	Sleuths []Sleuth
+	Songs   []Song
+}
+
+// New picks the podium and top songs out of a game's results
+func New(game string, scores []results.Score, consensus *results.Consensus, drawings map[string]string) *Recap {
+	r := &Recap{Game: game}
+	for i, s := range scores {
+		if i == podium {
+			break
+		}
+		r.Sleuths = append(r.Sleuths, Sleuth{Nickname: s.Nickname, Points: s.Points, Drawing: drawings[s.PlayerID]})
+	}
+	for i, s := range consensus.Songs {
+		if i == topSongs {
+			break
+		}
+		r.Songs = append(r.Songs, Song{Name: s.Name, Nickname: s.Nickname, AlbumArt: s.AlbumArt})
+	}
+	return r
+}
+
+// PNG renders the recap image
+func (r *Recap) PNG() ([]byte, error) {
+	// Title, two headed sections, then the bottom margin
+	height := padding + 40 + padding/2 +
+		32 + rowHeight*len(r.Sleuths) + padding/2 +
+		32 + rowHeight*len(r.Songs) + padding
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(background), image.Point{}, draw.Src)
+
+	y := padding
+	text(img, truncate(r.Game, 34), padding, y, 3, white)
+	y += 40 + padding/2
+
+	text(img, "TOP SLEUTHS", padding, y, 2, accent)
+	y += 32
+	for i, s := range r.Sleuths {
+		row(img, y, fmt.Sprintf("%d. %s", i+1, s.Nickname), fmt.Sprintf("%g pts", s.Points), dataURL(s.Drawing))
+		y += rowHeight
+	}
+
+	y += padding / 2
+	text(img, "TOP SONGS", padding, y, 2, accent)
+	y += 32
+	for i, s := range r.Songs {
+		row(img, y, fmt.Sprintf("%d. %s", i+1, s.Name), "from "+s.Nickname, fetch(s.AlbumArt))
+		y += rowHeight
+	}
+
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// row draws a thumbnail with a title and subtitle beside it
+func row(img *image.RGBA, y int, title, subtitle string, thumb image.Image) {
+	box := image.Rect(padding, y, width-padding, y+thumbSize+8)
+	draw.Draw(img, box, image.NewUniform(panel), image.Point{}, draw.Src)
+
+	dst := image.Rect(padding+4, y+4, padding+4+thumbSize, y+4+thumbSize)
+	if thumb != nil {
+		draw.ApproxBiLinear.Scale(img, dst, thumb, thumb.Bounds(), draw.Over, nil)
+	} else {
+		draw.Draw(img, dst, image.NewUniform(background), image.Point{}, draw.Src)
+	}
+
+	x := dst.Max.X + 16
+	text(img, truncate(title, 40), x, y+12, 2, white)
+	text(img, truncate(subtitle, 80), x, y+44, 1, muted)
+}
+
+// text draws s with its top left corner at (x, y). The bitmap font is only
+// 13px tall, so larger text is drawn small and scaled up.
+func text(img *image.RGBA, s string, x, y, scale int, c color.Color) {
+	face := basicfont.Face7x13
+	w := font.MeasureString(face, s).Ceil()
+	h := face.Height
+	small := image.NewRGBA(image.Rect(0, 0, w, h))
+	d := &font.Drawer{
+		Dst:  small,
+		Src:  image.NewUniform(c),
+		Face: face,
+		Dot:  fixed.P(0, face.Ascent),
+	}
+	d.DrawString(s)
+	dst := image.Rect(x, y, x+w*scale, y+h*scale)
+	draw.NearestNeighbor.Scale(img, dst, small, small.Bounds(), draw.Over, nil)
+}
+
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
+
+// dataURL decodes a base64 image data URL, such as a player's drawing
+func dataURL(s string) image.Image {
+	_, data, ok := strings.Cut(s, ";base64,")
+	if !ok {
+		return nil
+	}
+	if base64.StdEncoding.DecodedLen(len(data)) > maxBytes {
+		return nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil
+	}
+	return decode(raw)
+}
+
+// decode decodes an image, or returns nil if it can't be decoded or is too
+// big to decode safely
+func decode(raw []byte) image.Image {
+	config, _, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil || config.Width*config.Height > maxPixels {
+		return nil
+	}
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	return img
+}
+
+var client = &http.Client{Timeout: 5 * time.Second}
+
+// fetch downloads an image, returning nil if it can't be loaded so a missing
+// cover doesn't spoil the whole recap
+func fetch(url string) image.Image {
+	if url == "" {
+		return nil
+	}
+	res, err := client.Get(url)
+	if err != nil {
+		return nil
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil
+	}
+	raw, err := io.ReadAll(io.LimitReader(res.Body, maxBytes))
+	if err != nil {
+		return nil
+	}
+	return decode(raw)
+}
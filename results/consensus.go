@@ -0,0 +1,213 @@
+package results
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/charliekim2/songsleuths/db"
+)
+
+type TierVotes struct {
+	Tier  string `json:"tier"`
+	Votes int    `json:"votes"`
+}
+
+type SongStanding struct {
+	SongID       uint        `json:"song_id"`
+	Name         string      `json:"name"`
+	Spotify      string      `json:"spotify"`
+	AlbumArt     string      `json:"album_art"`
+	PlayerID     string      `json:"player_id"`
+	Nickname     string      `json:"nickname"`
+	Points       int         `json:"points"`
+	Votes        int         `json:"votes"`
+	AverageTier  float64     `json:"average_tier"` // Mean tier rank, 0 = top tier
+	Distribution []TierVotes `json:"distribution"`
+	Strength     float64     `json:"strength,omitempty"` // Bradley-Terry strength in pairwise games
+	Rank         int         `json:"rank"`
+	Round        uint        `json:"round,omitempty"` // Set in multi-round games, songs rank within their round
+}
+
+type SubmitterStanding struct {
+	PlayerID string  `json:"player_id"`
+	Nickname string  `json:"nickname"`
+	Points   int     `json:"points"`
+	Strength float64 `json:"strength,omitempty"`
+	Rank     int     `json:"rank"`
+}
+
+type Consensus struct {
+	Songs      []SongStanding      `json:"songs"`
+	Submitters []SubmitterStanding `json:"submitters"`
+	Winner     *SubmitterStanding  `json:"winner,omitempty"`
+}
+
+// ScoreSongs ranks the game's songs using the game's ranking mode. Songs in
+// multi-round games are ranked within their round.
+func ScoreSongs(game *db.Game) (*Consensus, error) {
+	views := roundsOf(game)
+	if len(views) == 1 {
+		return scoreSongs(game)
+	}
+	rounds := []*Consensus{}
+	for _, view := range views {
+		consensus, err := scoreSongs(view)
+		if err != nil {
+			return nil, err
+		}
+		rounds = append(rounds, consensus)
+	}
+	return mergeConsensus(rounds), nil
+}
+
+func scoreSongs(game *db.Game) (*Consensus, error) {
+	if game.RankingMode == "pairwise" {
+		return ScorePairwise(game)
+	}
+	return ScoreRankings(game)
+}
+
+// ScoreRankings combines every player's ranking list into a consensus order
+// of songs. Each placement is worth Borda points: the top tier earns one point
+// per tier in the list, the bottom tier earns one. Unranked songs earn nothing.
+func ScoreRankings(game *db.Game) (*Consensus, error) {
+	list := tierlist(game, "ranking")
+	if list == nil {
+		return nil, errors.New("game has no ranking list")
+	}
+
+	tiers := append([]db.Tier{}, list.Tiers...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Rank < tiers[j].Rank })
+	position := make(map[uint]int) // Tier id -> index in tiers, 0 = best
+	for i, tier := range tiers {
+		position[tier.ID] = i
+	}
+
+	standings := make(map[uint]*SongStanding)
+	counts := make(map[uint][]int) // Song id -> votes per tier position
+	for _, s := range game.Submissions {
+		for _, song := range s.Songs {
+			standings[song.ID] = &SongStanding{
+				SongID:   song.ID,
+				Name:     song.Name,
+				Spotify:  song.Spotify,
+				AlbumArt: song.AlbumArt,
+				PlayerID: s.PlayerID,
+				Nickname: s.Nickname,
+			}
+			counts[song.ID] = make([]int, len(tiers))
+		}
+	}
+
+	for _, r := range rankings(game, list.ID) {
+		placement, err := parseRanking(r.Ranking)
+		if err != nil {
+			return nil, err
+		}
+		for tierID, songIDs := range placement {
+			pos, ok := position[tierID]
+			if !ok {
+				continue
+			}
+			for _, sid := range songIDs {
+				standing, ok := standings[sid]
+				if !ok {
+					continue
+				}
+				standing.Points += len(tiers) - pos
+				standing.Votes++
+				counts[sid][pos]++
+			}
+		}
+	}
+
+	songs := []SongStanding{}
+	for sid, standing := range standings {
+		total := 0
+		for pos, votes := range counts[sid] {
+			total += pos * votes
+			standing.Distribution = append(standing.Distribution, TierVotes{Tier: tiers[pos].Name, Votes: votes})
+		}
+		if standing.Votes > 0 {
+			standing.AverageTier = float64(total) / float64(standing.Votes)
+		}
+		songs = append(songs, *standing)
+	}
+	// Ties go to the song with more votes in higher tiers, then the earlier submission
+	better := func(a, b SongStanding) bool {
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		for pos := range a.Distribution {
+			if a.Distribution[pos].Votes != b.Distribution[pos].Votes {
+				return a.Distribution[pos].Votes > b.Distribution[pos].Votes
+			}
+		}
+		return false
+	}
+	sort.Slice(songs, func(i, j int) bool {
+		if better(songs[i], songs[j]) || better(songs[j], songs[i]) {
+			return better(songs[i], songs[j])
+		}
+		return songs[i].SongID < songs[j].SongID
+	})
+	for i := range songs {
+		if i > 0 && !better(songs[i-1], songs[i]) {
+			songs[i].Rank = songs[i-1].Rank
+		} else {
+			songs[i].Rank = i + 1
+		}
+	}
+
+	consensus := &Consensus{Songs: songs, Submitters: submitterStandings(game, songs)}
+	if len(consensus.Submitters) > 0 && consensus.Submitters[0].Points > 0 {
+		consensus.Winner = &consensus.Submitters[0]
+	}
+	return consensus, nil
+}
+
+// submitterStandings totals the points and strength of each player's songs
+func submitterStandings(game *db.Game, songs []SongStanding) []SubmitterStanding {
+	points := make(map[string]int)
+	strength := make(map[string]float64)
+	for _, song := range songs {
+		points[song.PlayerID] += song.Points
+		strength[song.PlayerID] += song.Strength
+	}
+
+	submitters := []SubmitterStanding{}
+	for _, s := range game.Submissions {
+		// Decoys belong to nobody, so can't win
+		if s.PlayerID == db.DecoyPlayer {
+			continue
+		}
+		submitters = append(submitters, SubmitterStanding{
+			PlayerID: s.PlayerID,
+			Nickname: s.Nickname,
+			Points:   points[s.PlayerID],
+			Strength: strength[s.PlayerID],
+		})
+	}
+	rankSubmitters(submitters)
+	return submitters
+}
+
+// rankSubmitters sorts submitters, best first, and ranks them with ties sharing a rank
+func rankSubmitters(submitters []SubmitterStanding) {
+	sort.Slice(submitters, func(i, j int) bool {
+		if submitters[i].Strength != submitters[j].Strength {
+			return submitters[i].Strength > submitters[j].Strength
+		}
+		if submitters[i].Points != submitters[j].Points {
+			return submitters[i].Points > submitters[j].Points
+		}
+		return submitters[i].Nickname < submitters[j].Nickname
+	})
+	for i := range submitters {
+		if i > 0 && submitters[i].Strength == submitters[i-1].Strength && submitters[i].Points == submitters[i-1].Points {
+			submitters[i].Rank = submitters[i-1].Rank
+		} else {
+			submitters[i].Rank = i + 1
+		}
+	}
+}
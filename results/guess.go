@@ -0,0 +1,201 @@
+package results
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strconv"
+
+	"github.com/charliekim2/songsleuths/db"
+)
+
+// Guess is a single song placement from a player's guess list
+type Guess struct {
+	PlayerID string // Player who made the guess
+	SongID   uint
+	Owner    string // Player who submitted the song, or their TeamKey in teams mode
+	Guessed  string // Owner the song was placed under, empty if left unranked
+}
+
+func (g Guess) Correct() bool {
+	return g.Guessed != "" && g.Guessed == g.Owner
+}
+
+type Score struct {
+	PlayerID string  `json:"player_id"`
+	Nickname string  `json:"nickname"`
+	Correct  int     `json:"correct"`
+	Total    int     `json:"total"`
+	Percent  float64 `json:"percent"`
+	Points   float64 `json:"points"` // Awarded by the game's scoring strategy
+	Rank     int     `json:"rank"`   // 1 = best, tied players share a rank
+}
+
+// parseRanking decodes ranking JSON (tier id -> song ids) into numeric ids
+func parseRanking(raw string) (map[uint][]uint, error) {
+	var tiers map[string][]string
+	if err := json.Unmarshal([]byte(raw), &tiers); err != nil {
+		return nil, err
+	}
+	parsed := make(map[uint][]uint)
+	for tierID, songIDs := range tiers {
+		tid, err := strconv.ParseUint(tierID, 10, 0)
+		if err != nil {
+			return nil, errors.New("invalid tier id " + tierID)
+		}
+		for _, songID := range songIDs {
+			sid, err := strconv.ParseUint(songID, 10, 0)
+			if err != nil {
+				return nil, errors.New("invalid song id " + songID)
+			}
+			parsed[uint(tid)] = append(parsed[uint(tid)], uint(sid))
+		}
+	}
+	return parsed, nil
+}
+
+// Guesses lists every player's guess for every song they did not submit
+// themselves. Songs left unranked count as guesses for nobody. Decoy songs
+// are owned by db.DecoyPlayer, so are correct when placed in the decoy tier.
+func Guesses(game *db.Game) ([]Guess, error) {
+	list := tierlist(game, "guess")
+	if list == nil {
+		return nil, errors.New("game has no guess list")
+	}
+
+	// Who submitted each song, and whose submission each guess tier stands for
+	owners := OwnersOf(game)
+	songs := []uint{}
+	for sid := range owners.Songs {
+		songs = append(songs, sid)
+	}
+	sort.Slice(songs, func(i, j int) bool { return songs[i] < songs[j] })
+
+	guesses := []Guess{}
+	for _, r := range rankings(game, list.ID) {
+		placement, err := parseRanking(r.Ranking)
+		if err != nil {
+			return nil, err
+		}
+		guessed := make(map[uint]string)
+		for tierID, songIDs := range placement {
+			owner, ok := owners.Tiers[tierID]
+			if !ok {
+				continue
+			}
+			for _, sid := range songIDs {
+				guessed[sid] = owner
+			}
+		}
+		// Players don't guess their own songs, or in teams mode their team's
+		side := owners.Side(r.PlayerID)
+		for _, sid := range songs {
+			if owners.Songs[sid] == side {
+				continue
+			}
+			guesses = append(guesses, Guess{
+				PlayerID: r.PlayerID,
+				SongID:   sid,
+				Owner:    owners.Songs[sid],
+				Guessed:  guessed[sid],
+			})
+		}
+	}
+	return guesses, nil
+}
+
+// ScoreGuesses counts each player's correct guesses, awards points using the
+// game's scoring strategy and returns them as a leaderboard, best first.
+// Multi-round games are scored round by round and added up.
+func ScoreGuesses(game *db.Game) ([]Score, error) {
+	views := roundsOf(game)
+	if len(views) == 1 {
+		return scoreGuesses(game)
+	}
+	rounds := [][]Score{}
+	for _, view := range views {
+		scores, err := scoreGuesses(view)
+		if err != nil {
+			return nil, err
+		}
+		rounds = append(rounds, scores)
+	}
+	return mergeScores(rounds), nil
+}
+
+func scoreGuesses(game *db.Game) ([]Score, error) {
+	strategy, err := StrategyFor(game.Scoring)
+	if err != nil {
+		return nil, err
+	}
+	guesses, err := Guesses(game)
+	if err != nil {
+		return nil, err
+	}
+
+	nicknames := make(map[string]string)
+	for _, s := range game.Submissions {
+		nicknames[s.PlayerID] = s.Nickname
+	}
+
+	byPlayer := make(map[string]*Score)
+	// Players with an empty guess list still get a row
+	if list := tierlist(game, "guess"); list != nil {
+		for _, r := range rankings(game, list.ID) {
+			byPlayer[r.PlayerID] = &Score{PlayerID: r.PlayerID, Nickname: nicknames[r.PlayerID]}
+		}
+	}
+	for _, g := range guesses {
+		score := byPlayer[g.PlayerID]
+		score.Total++
+		if g.Correct() {
+			score.Correct++
+		}
+	}
+	teams := make(map[string]bool)
+	for _, t := range game.Teams {
+		teams[TeamKey(t.ID)] = true
+	}
+	for pid, points := range strategy.Points(guesses) {
+		// Points owed to a team show up in ScoreTeams instead, and decoys
+		// aren't players
+		if teams[pid] || pid == db.DecoyPlayer {
+			continue
+		}
+		// Some strategies reward submitters who never guessed
+		if _, ok := byPlayer[pid]; !ok {
+			byPlayer[pid] = &Score{PlayerID: pid, Nickname: nicknames[pid]}
+		}
+		byPlayer[pid].Points = points
+	}
+
+	scores := []Score{}
+	for _, score := range byPlayer {
+		if score.Total > 0 {
+			score.Percent = float64(score.Correct) / float64(score.Total) * 100
+		}
+		scores = append(scores, *score)
+	}
+	rankScores(scores)
+	return scores, nil
+}
+
+// rankScores sorts a leaderboard, best first, and ranks it with ties sharing a rank
+func rankScores(scores []Score) {
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Points != scores[j].Points {
+			return scores[i].Points > scores[j].Points
+		}
+		if scores[i].Correct != scores[j].Correct {
+			return scores[i].Correct > scores[j].Correct
+		}
+		return scores[i].Nickname < scores[j].Nickname
+	})
+	for i := range scores {
+		if i > 0 && scores[i].Points == scores[i-1].Points && scores[i].Correct == scores[i-1].Correct {
+			scores[i].Rank = scores[i-1].Rank
+		} else {
+			scores[i].Rank = i + 1
+		}
+	}
+}
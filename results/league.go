@@ -0,0 +1,110 @@
+package results
+
+import (
+	"sort"
+
+	"github.com/charliekim2/songsleuths/db"
+	"gorm.io/gorm"
+)
+
+type Standing struct {
+	PlayerID      string  `json:"player_id"`
+	Nickname      string  `json:"nickname"` // Most recent nickname used in the league
+	Games         int     `json:"games"`
+	Correct       int     `json:"correct"`
+	GuessPoints   float64 `json:"guess_points"`
+	RankingPoints int     `json:"ranking_points"` // Consensus points earned by the player's songs
+	Wins          int     `json:"wins"`           // Games where the player's songs topped the consensus
+	Rank          int     `json:"rank"`
+}
+
+// LoadLeague fetches a league and its games with everything needed to
+// compute their results
+func LoadLeague(conn *gorm.DB, id string) (*db.League, error) {
+	league := &db.League{}
+	err := conn.
+		Preload("Games.Tierlists.Tiers").
+		Preload("Games.Submissions.Songs").
+		Preload("Games.Rankings").
+		Preload("Games.Votes").
+		Preload("Games.Teams").
+		First(league, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return league, nil
+}
+
+// Standings adds up every player's guess and ranking results across games.
+// Players are ordered by guess points, then wins, then ranking points.
+func Standings(games []*db.Game) ([]Standing, error) {
+	byPlayer := make(map[string]*Standing)
+	standing := func(pid string) *Standing {
+		if _, ok := byPlayer[pid]; !ok {
+			byPlayer[pid] = &Standing{PlayerID: pid}
+		}
+		return byPlayer[pid]
+	}
+
+	// Oldest game first so the latest nickname wins
+	sorted := append([]*db.Game{}, games...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Deadline < sorted[j].Deadline })
+	for _, game := range sorted {
+		scores, err := ScoreGuesses(game)
+		if err != nil {
+			return nil, err
+		}
+		consensus, err := ScoreSongs(game)
+		if err != nil {
+			return nil, err
+		}
+
+		played := make(map[string]bool)
+		for _, score := range scores {
+			s := standing(score.PlayerID)
+			s.Correct += score.Correct
+			s.GuessPoints += score.Points
+			played[score.PlayerID] = true
+		}
+		for _, sub := range consensus.Submitters {
+			s := standing(sub.PlayerID)
+			s.Nickname = sub.Nickname
+			s.RankingPoints += sub.Points
+			played[sub.PlayerID] = true
+		}
+		if consensus.Winner != nil {
+			standing(consensus.Winner.PlayerID).Wins++
+		}
+		for pid := range played {
+			standing(pid).Games++
+		}
+	}
+
+	standings := []Standing{}
+	for _, s := range byPlayer {
+		standings = append(standings, *s)
+	}
+	better := func(a, b Standing) bool {
+		if a.GuessPoints != b.GuessPoints {
+			return a.GuessPoints > b.GuessPoints
+		}
+		if a.Wins != b.Wins {
+			return a.Wins > b.Wins
+		}
+		return a.RankingPoints > b.RankingPoints
+	}
+	sort.Slice(standings, func(i, j int) bool {
+		if better(standings[i], standings[j]) || better(standings[j], standings[i]) {
+			return better(standings[i], standings[j])
+		}
+		return standings[i].Nickname < standings[j].Nickname
+	})
+	for i := range standings {
+		if i > 0 && !better(standings[i-1], standings[i]) {
+			standings[i].Rank = standings[i-1].Rank
+		} else {
+			standings[i].Rank = i + 1
+		}
+	}
+	return standings, nil
+}
@@ -0,0 +1,99 @@
+package results
+
+import (
+	"sort"
+
+	"github.com/charliekim2/songsleuths/db"
+	"gorm.io/gorm"
+)
+
+// LoadGame fetches a game with everything needed to compute its results
+func LoadGame(conn *gorm.DB, gid string) (*db.Game, error) {
+	game := &db.Game{}
+	err := conn.
+		Preload("Tierlists.Tiers").
+		Preload("Submissions.Songs").
+		Preload("Rankings").
+		Preload("Votes").
+		Preload("Teams").
+		First(game, "id = ?", gid).Error
+	if err != nil {
+		return nil, err
+	}
+	return game, nil
+}
+
+// tierlist returns the game's tierlist of the given type ("guess" or "ranking")
+func tierlist(game *db.Game, listType string) *db.Tierlist {
+	for i := range game.Tierlists {
+		if game.Tierlists[i].Type == listType {
+			return &game.Tierlists[i]
+		}
+	}
+	return nil
+}
+
+// rankings returns every ranking submitted for a tierlist
+func rankings(game *db.Game, tierlistID uint) []db.Ranking {
+	list := []db.Ranking{}
+	for _, r := range game.Rankings {
+		if r.TierlistID == tierlistID {
+			list = append(list, r)
+		}
+	}
+	return list
+}
+
+type Placement struct {
+	PlayerID string `json:"player_id"`
+	List     string `json:"list"` // "guess" or "ranking"
+	TierID   uint   `json:"tier_id"`
+	Tier     string `json:"tier"`
+	SongID   uint   `json:"song_id"`
+}
+
+// Placements flattens every ranking in the game into one row per placed song
+func Placements(game *db.Game) ([]Placement, error) {
+	tiers := make(map[uint]db.Tier)
+	lists := make(map[uint]string)
+	for _, list := range game.Tierlists {
+		lists[list.ID] = list.Type
+		for _, tier := range list.Tiers {
+			tiers[tier.ID] = tier
+		}
+	}
+
+	placements := []Placement{}
+	for _, r := range game.Rankings {
+		placement, err := parseRanking(r.Ranking)
+		if err != nil {
+			return nil, err
+		}
+		for tierID, songIDs := range placement {
+			tier, ok := tiers[tierID]
+			if !ok || tier.TierlistID != r.TierlistID {
+				continue
+			}
+			for _, sid := range songIDs {
+				placements = append(placements, Placement{
+					PlayerID: r.PlayerID,
+					List:     lists[r.TierlistID],
+					TierID:   tierID,
+					Tier:     tier.Name,
+					SongID:   sid,
+				})
+			}
+		}
+	}
+	sort.Slice(placements, func(i, j int) bool {
+		a, b := placements[i], placements[j]
+		if a.PlayerID != b.PlayerID {
+			return a.PlayerID < b.PlayerID
+		}
+		if a.List != b.List {
+			return a.List < b.List
+		}
+		return a.SongID < b.SongID
+	})
+	return placements, nil
+}
@@ -0,0 +1,124 @@
+package results
+
+import (
+	"math"
+	"sort"
+
+	"github.com/charliekim2/songsleuths/db"
+)
+
+const (
+	btIterations = 200
+	btTolerance  = 1e-9
+)
+
+// BradleyTerry estimates a strength for each song from head-to-head votes,
+// so that a beats b with probability s[a] / (s[a] + s[b]). Every song also
+// plays one virtual win and one virtual loss against an average opponent,
+// which keeps songs that never won (or never lost) finite. Strengths are
+// scaled so the average song has strength 1.
+func BradleyTerry(songs []uint, votes []db.PairwiseVote) map[uint]float64 {
+	strength := make(map[uint]float64)
+	wins := make(map[uint]float64)
+	for _, sid := range songs {
+		strength[sid] = 1
+		wins[sid] = 1 // Virtual win
+	}
+	type pair struct{ a, b uint }
+	games := make(map[pair]float64)
+	for _, v := range votes {
+		if _, ok := strength[v.WinnerID]; !ok {
+			continue
+		}
+		if _, ok := strength[v.LoserID]; !ok {
+			continue
+		}
+		wins[v.WinnerID]++
+		games[pair{v.WinnerID, v.LoserID}]++
+	}
+
+	// Minorization-maximization updates (Hunter, 2004)
+	for iter := 0; iter < btIterations; iter++ {
+		denom := make(map[uint]float64)
+		for _, sid := range songs {
+			denom[sid] = 2 / (strength[sid] + 1) // Virtual games against strength 1
+		}
+		for p, n := range games {
+			d := n / (strength[p.a] + strength[p.b])
+			denom[p.a] += d
+			denom[p.b] += d
+		}
+
+		next := make(map[uint]float64)
+		total := 0.0
+		for _, sid := range songs {
+			next[sid] = wins[sid] / denom[sid]
+			total += next[sid]
+		}
+		change := 0.0
+		for _, sid := range songs {
+			next[sid] *= float64(len(songs)) / total
+			change = math.Max(change, math.Abs(next[sid]-strength[sid]))
+		}
+		strength = next
+		if change < btTolerance {
+			break
+		}
+	}
+	return strength
+}
+
+// ScorePairwise orders a pairwise game's songs by Bradley-Terry strength.
+// Points count the head-to-head wins of each song.
+func ScorePairwise(game *db.Game) (*Consensus, error) {
+	standings := make(map[uint]*SongStanding)
+	songs := []uint{}
+	for _, s := range game.Submissions {
+		for _, song := range s.Songs {
+			standings[song.ID] = &SongStanding{
+				SongID:   song.ID,
+				Name:     song.Name,
+				Spotify:  song.Spotify,
+				AlbumArt: song.AlbumArt,
+				PlayerID: s.PlayerID,
+				Nickname: s.Nickname,
+			}
+			songs = append(songs, song.ID)
+		}
+	}
+	for _, v := range game.Votes {
+		if winner, ok := standings[v.WinnerID]; ok {
+			winner.Points++
+			winner.Votes++
+		}
+		if loser, ok := standings[v.LoserID]; ok {
+			loser.Votes++
+		}
+	}
+
+	strength := BradleyTerry(songs, game.Votes)
+	list := []SongStanding{}
+	for sid, standing := range standings {
+		standing.Strength = strength[sid]
+		list = append(list, *standing)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Strength != list[j].Strength {
+			return list[i].Strength > list[j].Strength
+		}
+		return list[i].SongID < list[j].SongID
+	})
+	for i := range list {
+		if i > 0 && list[i].Strength == list[i-1].Strength {
+			list[i].Rank = list[i-1].Rank
+		} else {
+			list[i].Rank = i + 1
+		}
+	}
+
+	consensus := &Consensus{Songs: list, Submitters: submitterStandings(game, list)}
+	if len(consensus.Submitters) > 0 && len(game.Votes) > 0 {
+		consensus.Winner = &consensus.Submitters[0]
+	}
+	return consensus, nil
+}
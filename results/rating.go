@@ -0,0 +1,107 @@
+package results
+
+import (
+	"math"
+
+	"github.com/charliekim2/songsleuths/db"
+	"gorm.io/gorm"
+)
+
+// How far a single game can move a rating
+const ratingK = 32
+
+// Elo treats a game as a round robin between its guessers: each pair plays a
+// match won by whoever scored more points. Returns each player's new rating.
+func Elo(ratings map[string]float64, scores []Score) map[string]float64 {
+	updated := make(map[string]float64)
+	if len(scores) < 2 {
+		for _, s := range scores {
+			updated[s.PlayerID] = ratings[s.PlayerID]
+		}
+		return updated
+	}
+
+	k := float64(ratingK) / float64(len(scores)-1)
+	for _, a := range scores {
+		delta := 0.0
+		for _, b := range scores {
+			if a.PlayerID == b.PlayerID {
+				continue
+			}
+			actual := 0.5
+			if a.Points > b.Points {
+				actual = 1
+			} else if a.Points < b.Points {
+				actual = 0
+			}
+			expected := 1 / (1 + math.Pow(10, (ratings[b.PlayerID]-ratings[a.PlayerID])/400))
+			delta += k * (actual - expected)
+		}
+		updated[a.PlayerID] = ratings[a.PlayerID] + delta
+	}
+	return updated
+}
+
+// Finalize applies a revealed game's guess results to its players' ratings.
+// It only runs once per game.
+func Finalize(conn *gorm.DB, gid string) error {
+	return conn.Transaction(func(tx *gorm.DB) error {
+		// Claim the game first so concurrent reveals don't rate it twice
+		res := tx.Model(&db.Game{}).Where("id = ? AND rated = ?", gid, false).Update("rated", true)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return nil
+		}
+
+		game, err := LoadGame(tx, gid)
+		if err != nil {
+			return err
+		}
+		scores, err := ScoreGuesses(game)
+		if err != nil {
+			return err
+		}
+
+		// Only players with an account can be rated
+		ids := []string{}
+		for _, s := range scores {
+			ids = append(ids, s.PlayerID)
+		}
+		players := []db.Player{}
+		if err := tx.Where("id IN ?", ids).Find(&players).Error; err != nil {
+			return err
+		}
+		ratings := make(map[string]float64)
+		for _, p := range players {
+			ratings[p.ID] = p.Rating
+		}
+		rated := []Score{}
+		for _, s := range scores {
+			if _, ok := ratings[s.PlayerID]; ok {
+				rated = append(rated, s)
+			}
+		}
+
+		for pid, after := range Elo(ratings, rated) {
+			err := tx.Model(&db.Player{}).Where("id = ?", pid).Updates(map[string]any{
+				"rating":      after,
+				"rated_games": gorm.Expr("rated_games + 1"),
+			}).Error
+			if err != nil {
+				return err
+			}
+			err = tx.Create(&db.RatingChange{
+				PlayerID: pid,
+				GameID:   gid,
+				Before:   ratings[pid],
+				After:    after,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
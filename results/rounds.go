@@ -0,0 +1,162 @@
+package results
+
+import (
+	"sort"
+
+	"github.com/charliekim2/songsleuths/db"
+)
+
+// Round narrows a loaded game down to one of its rounds: only that round's
+// tierlists, submissions, rankings and votes are kept, and the view counts as
+// a single-round game so it scores like one.
+func Round(game *db.Game, n uint) *db.Game {
+	view := *game
+	view.NRounds = 1
+	view.Tierlists = []db.Tierlist{}
+	view.Submissions = []db.Submission{}
+	view.Rankings = []db.Ranking{}
+	view.Votes = []db.PairwiseVote{}
+
+	lists := make(map[uint]bool)
+	for _, list := range game.Tierlists {
+		if list.Round == n {
+			view.Tierlists = append(view.Tierlists, list)
+			lists[list.ID] = true
+		}
+	}
+	for _, r := range game.Rankings {
+		if lists[r.TierlistID] {
+			view.Rankings = append(view.Rankings, r)
+		}
+	}
+	songs := make(map[uint]bool)
+	for _, s := range game.Submissions {
+		if s.Round == n {
+			view.Submissions = append(view.Submissions, s)
+			for _, song := range s.Songs {
+				songs[song.ID] = true
+			}
+		}
+	}
+	for _, v := range game.Votes {
+		if songs[v.WinnerID] && songs[v.LoserID] {
+			view.Votes = append(view.Votes, v)
+		}
+	}
+	return &view
+}
+
+// roundsOf splits a game into its rounds, in order. Single-round games are
+// returned as they are.
+func roundsOf(game *db.Game) []*db.Game {
+	if game.NRounds <= 1 {
+		return []*db.Game{game}
+	}
+	views := []*db.Game{}
+	for n := uint(1); n <= game.NRounds; n++ {
+		views = append(views, Round(game, n))
+	}
+	return views
+}
+
+// mergeScores adds up each player's guess scores across rounds
+func mergeScores(rounds [][]Score) []Score {
+	byPlayer := make(map[string]*Score)
+	for _, scores := range rounds {
+		for _, s := range scores {
+			total, ok := byPlayer[s.PlayerID]
+			if !ok {
+				total = &Score{PlayerID: s.PlayerID}
+				byPlayer[s.PlayerID] = total
+			}
+			// Latest round's nickname wins
+			if s.Nickname != "" {
+				total.Nickname = s.Nickname
+			}
+			total.Correct += s.Correct
+			total.Total += s.Total
+			total.Points += s.Points
+		}
+	}
+
+	scores := []Score{}
+	for _, score := range byPlayer {
+		if score.Total > 0 {
+			score.Percent = float64(score.Correct) / float64(score.Total) * 100
+		}
+		scores = append(scores, *score)
+	}
+	rankScores(scores)
+	return scores
+}
+
+// mergeTeams adds up each team's scores across rounds
+func mergeTeams(rounds [][]TeamScore) []TeamScore {
+	byTeam := make(map[uint]*TeamScore)
+	members := make(map[uint]map[string]bool)
+	for _, scores := range rounds {
+		for _, s := range scores {
+			total, ok := byTeam[s.TeamID]
+			if !ok {
+				total = &TeamScore{TeamID: s.TeamID, Name: s.Name, Members: []string{}}
+				byTeam[s.TeamID] = total
+				members[s.TeamID] = make(map[string]bool)
+			}
+			for _, m := range s.Members {
+				if !members[s.TeamID][m] {
+					members[s.TeamID][m] = true
+					total.Members = append(total.Members, m)
+				}
+			}
+			total.Correct += s.Correct
+			total.Total += s.Total
+			total.Points += s.Points
+		}
+	}
+
+	scores := []TeamScore{}
+	for _, score := range byTeam {
+		if score.Total > 0 {
+			score.Percent = float64(score.Correct) / float64(score.Total) * 100
+		}
+		sort.Strings(score.Members)
+		scores = append(scores, *score)
+	}
+	rankTeams(scores)
+	return scores
+}
+
+// mergeConsensus lists every round's songs, ranked within their round, and
+// totals each submitter's points across rounds
+func mergeConsensus(rounds []*Consensus) *Consensus {
+	merged := &Consensus{Songs: []SongStanding{}}
+	bySubmitter := make(map[string]*SubmitterStanding)
+	order := []string{}
+	for i, c := range rounds {
+		for _, song := range c.Songs {
+			song.Round = uint(i + 1)
+			merged.Songs = append(merged.Songs, song)
+		}
+		for _, s := range c.Submitters {
+			total, ok := bySubmitter[s.PlayerID]
+			if !ok {
+				total = &SubmitterStanding{PlayerID: s.PlayerID}
+				bySubmitter[s.PlayerID] = total
+				order = append(order, s.PlayerID)
+			}
+			total.Nickname = s.Nickname
+			total.Points += s.Points
+			total.Strength += s.Strength
+		}
+	}
+
+	merged.Submitters = []SubmitterStanding{}
+	for _, pid := range order {
+		merged.Submitters = append(merged.Submitters, *bySubmitter[pid])
+	}
+	rankSubmitters(merged.Submitters)
+	if len(merged.Submitters) > 0 && (merged.Submitters[0].Points > 0 || merged.Submitters[0].Strength > 0) {
+		merged.Winner = &merged.Submitters[0]
+	}
+	return merged
+}
@@ -0,0 +1,186 @@
+package results
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/charliekim2/songsleuths/db"
+)
+
+type Member struct {
+	PlayerID string `json:"player_id"`
+	Nickname string `json:"nickname"`
+}
+
+type Twin struct {
+	PlayerID string  `json:"player_id"`
+	TwinID   string  `json:"twin_id"`
+	Tau      float64 `json:"tau"`
+}
+
+type Similarity struct {
+	Players []Member `json:"players"`
+	// Kendall tau-b between players i and j, null when they share too few
+	// ranked songs to compare
+	Matrix [][]*float64 `json:"matrix"`
+	Twins  []Twin       `json:"twins"` // Each player's most similar player
+}
+
+// pairCounts tallies song pairs between two players' rankings for tau-b
+type pairCounts struct {
+	concordant, discordant int
+	tiedA, tiedB           int // Pairs tied by only one of the players
+}
+
+func (c pairCounts) tau() (float64, bool) {
+	n := c.concordant + c.discordant
+	denom := math.Sqrt(float64(n+c.tiedA) * float64(n+c.tiedB))
+	if denom == 0 {
+		return 0, false
+	}
+	return float64(c.concordant-c.discordant) / denom, true
+}
+
+// tierPositions maps each song a player ranked to its tier, 0 = top tier
+func tierPositions(game *db.Game) (map[string]map[uint]int, error) {
+	list := tierlist(game, "ranking")
+	if list == nil {
+		return nil, errors.New("game has no ranking list")
+	}
+	tiers := append([]db.Tier{}, list.Tiers...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].Rank < tiers[j].Rank })
+	position := make(map[uint]int)
+	for i, tier := range tiers {
+		position[tier.ID] = i
+	}
+
+	players := make(map[string]map[uint]int)
+	for _, r := range rankings(game, list.ID) {
+		placement, err := parseRanking(r.Ranking)
+		if err != nil {
+			return nil, err
+		}
+		players[r.PlayerID] = make(map[uint]int)
+		for tierID, songIDs := range placement {
+			pos, ok := position[tierID]
+			if !ok {
+				continue
+			}
+			for _, sid := range songIDs {
+				players[r.PlayerID][sid] = pos
+			}
+		}
+	}
+	return players, nil
+}
+
+// Similarities compares every pair of players' song rankings using Kendall
+// tau-b. Across several games, song pairs from every game are pooled. Each
+// round of a multi-round game counts as a game of its own.
+func Similarities(games []*db.Game) (*Similarity, error) {
+	rounds := []*db.Game{}
+	for _, game := range games {
+		rounds = append(rounds, roundsOf(game)...)
+	}
+
+	counts := make(map[[2]string]*pairCounts)
+	nicknames := make(map[string]string)
+	for _, game := range rounds {
+		// Head-to-head votes are too sparse to compare players with
+		if game.RankingMode == "pairwise" {
+			continue
+		}
+		for _, s := range game.Submissions {
+			// Decoys are songs, not players to compare
+			if s.PlayerID != db.DecoyPlayer {
+				nicknames[s.PlayerID] = s.Nickname
+			}
+		}
+		positions, err := tierPositions(game)
+		if err != nil {
+			return nil, err
+		}
+		for a, posA := range positions {
+			if _, ok := nicknames[a]; !ok {
+				nicknames[a] = ""
+			}
+			for b, posB := range positions {
+				if a >= b {
+					continue
+				}
+				key := [2]string{a, b}
+				if counts[key] == nil {
+					counts[key] = &pairCounts{}
+				}
+				tally(counts[key], posA, posB)
+			}
+		}
+	}
+
+	sim := &Similarity{Players: []Member{}, Matrix: [][]*float64{}, Twins: []Twin{}}
+	for pid, nickname := range nicknames {
+		sim.Players = append(sim.Players, Member{PlayerID: pid, Nickname: nickname})
+	}
+	sort.Slice(sim.Players, func(i, j int) bool { return sim.Players[i].PlayerID < sim.Players[j].PlayerID })
+
+	for i, a := range sim.Players {
+		row := make([]*float64, len(sim.Players))
+		var twin *Twin
+		for j, b := range sim.Players {
+			if i == j {
+				one := 1.0
+				row[j] = &one
+				continue
+			}
+			key := [2]string{a.PlayerID, b.PlayerID}
+			if key[0] > key[1] {
+				key = [2]string{key[1], key[0]}
+			}
+			c, ok := counts[key]
+			if !ok {
+				continue
+			}
+			tau, ok := c.tau()
+			if !ok {
+				continue
+			}
+			row[j] = &tau
+			if twin == nil || tau > twin.Tau {
+				twin = &Twin{PlayerID: a.PlayerID, TwinID: b.PlayerID, Tau: tau}
+			}
+		}
+		sim.Matrix = append(sim.Matrix, row)
+		if twin != nil {
+			sim.Twins = append(sim.Twins, *twin)
+		}
+	}
+	return sim, nil
+}
+
+// tally adds every pair of songs both players ranked to the counts
+func tally(c *pairCounts, posA, posB map[uint]int) {
+	shared := []uint{}
+	for sid := range posA {
+		if _, ok := posB[sid]; ok {
+			shared = append(shared, sid)
+		}
+	}
+	for i := range shared {
+		for j := i + 1; j < len(shared); j++ {
+			diffA := posA[shared[i]] - posA[shared[j]]
+			diffB := posB[shared[i]] - posB[shared[j]]
+			switch {
+			case diffA == 0 && diffB == 0:
+			case diffA == 0:
+				c.tiedA++
+			case diffB == 0:
+				c.tiedB++
+			case (diffA > 0) == (diffB > 0):
+				c.concordant++
+			default:
+				c.discordant++
+			}
+		}
+	}
+}
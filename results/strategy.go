@@ -0,0 +1,104 @@
+package results
+
+import "errors"
+
+// Strategy turns the guesses made in a game into points per player
+type Strategy interface {
+	Points(guesses []Guess) map[string]float64
+}
+
+// Strategies are the built-in scoring rules, keyed by the name stored on db.Game
+var Strategies = map[string]Strategy{
+	"flat":     Flat{},
+	"weighted": Weighted{},
+	"penalty":  Penalty{},
+	"stealth":  Stealth{},
+}
+
+const DefaultStrategy = "flat"
+
+func StrategyFor(name string) (Strategy, error) {
+	if name == "" {
+		name = DefaultStrategy
+	}
+	s, ok := Strategies[name]
+	if !ok {
+		return nil, errors.New("unknown scoring strategy " + name)
+	}
+	return s, nil
+}
+
+// Flat awards one point per correct guess
+type Flat struct{}
+
+func (Flat) Points(guesses []Guess) map[string]float64 {
+	points := make(map[string]float64)
+	for _, g := range guesses {
+		points[g.PlayerID] += 0
+		if g.Correct() {
+			points[g.PlayerID]++
+		}
+	}
+	return points
+}
+
+// Weighted gives partial credit by difficulty: a correct guess is worth a full
+// point if nobody else identified the song, and 1/n if all n guessers did
+type Weighted struct{}
+
+func (Weighted) Points(guesses []Guess) map[string]float64 {
+	guessers := make(map[uint]int)
+	correct := make(map[uint]int)
+	for _, g := range guesses {
+		guessers[g.SongID]++
+		if g.Correct() {
+			correct[g.SongID]++
+		}
+	}
+
+	points := make(map[string]float64)
+	for _, g := range guesses {
+		points[g.PlayerID] += 0
+		if g.Correct() {
+			points[g.PlayerID] += float64(guessers[g.SongID]-correct[g.SongID]+1) / float64(guessers[g.SongID])
+		}
+	}
+	return points
+}
+
+// Penalty awards a point per correct guess and takes one away for each song
+// confidently placed under the wrong player. Leaving a song unranked is free.
+type Penalty struct{}
+
+func (Penalty) Points(guesses []Guess) map[string]float64 {
+	points := make(map[string]float64)
+	for _, g := range guesses {
+		points[g.PlayerID] += 0
+		if g.Correct() {
+			points[g.PlayerID]++
+		} else if g.Guessed != "" {
+			points[g.PlayerID]--
+		}
+	}
+	return points
+}
+
+// Stealth scores guesses like Flat, and also awards a point to the submitter
+// of every song that nobody identified
+type Stealth struct{}
+
+func (Stealth) Points(guesses []Guess) map[string]float64 {
+	points := Flat{}.Points(guesses)
+	identified := make(map[uint]bool)
+	owners := make(map[uint]string)
+	for _, g := range guesses {
+		owners[g.SongID] = g.Owner
+		identified[g.SongID] = identified[g.SongID] || g.Correct()
+	}
+	for sid, owner := range owners {
+		if !identified[sid] {
+			points[owner]++
+		}
+	}
+	return points
+}
@@ -0,0 +1,170 @@
+package results
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/charliekim2/songsleuths/db"
+)
+
+// TeamKey identifies a team wherever guesses would otherwise name a player
+func TeamKey(teamID uint) string {
+	return fmt.Sprintf("team-%d", teamID)
+}
+
+// Owners says who each song and guess tier belongs to for guessing: the
+// submitter, or in teams mode the submitter's team. Players maps each
+// submitter to the side they guess for.
+type Owners struct {
+	Songs   map[uint]string
+	Tiers   map[uint]string
+	Players map[string]string
+}
+
+func OwnersOf(game *db.Game) Owners {
+	o := Owners{
+		Songs:   make(map[uint]string),
+		Tiers:   make(map[uint]string),
+		Players: make(map[string]string),
+	}
+	submitters := make(map[uint]string)
+	for _, s := range game.Submissions {
+		side := s.PlayerID
+		if s.TeamID != nil {
+			side = TeamKey(*s.TeamID)
+		}
+		submitters[s.ID] = side
+		o.Players[s.PlayerID] = side
+		for _, song := range s.Songs {
+			o.Songs[song.ID] = side
+		}
+	}
+	// Every round's guess list, so whole multi-round games can be checked
+	for _, list := range game.Tierlists {
+		if list.Type != "guess" {
+			continue
+		}
+		for _, tier := range list.Tiers {
+			if tier.SubmissionID != nil {
+				o.Tiers[tier.ID] = submitters[*tier.SubmissionID]
+			} else if tier.TeamID != nil {
+				o.Tiers[tier.ID] = TeamKey(*tier.TeamID)
+			}
+		}
+	}
+	return o
+}
+
+// Side returns who a player guesses for, themselves unless they're on a team
+func (o Owners) Side(playerID string) string {
+	if side, ok := o.Players[playerID]; ok {
+		return side
+	}
+	return playerID
+}
+
+type TeamScore struct {
+	TeamID  uint     `json:"team_id"`
+	Name    string   `json:"name"`
+	Members []string `json:"members"` // Nicknames
+	Correct int      `json:"correct"`
+	Total   int      `json:"total"`
+	Percent float64  `json:"percent"`
+	Points  float64  `json:"points"`
+	Rank    int      `json:"rank"`
+}
+
+// ScoreTeams scores guesses at team level: each guess counts for the
+// guesser's team and the game's scoring strategy is applied to the teams.
+// Returns nil if the game has no teams.
+func ScoreTeams(game *db.Game) ([]TeamScore, error) {
+	if len(game.Teams) == 0 {
+		return nil, nil
+	}
+	views := roundsOf(game)
+	if len(views) == 1 {
+		return scoreTeams(game)
+	}
+	rounds := [][]TeamScore{}
+	for _, view := range views {
+		scores, err := scoreTeams(view)
+		if err != nil {
+			return nil, err
+		}
+		rounds = append(rounds, scores)
+	}
+	return mergeTeams(rounds), nil
+}
+
+func scoreTeams(game *db.Game) ([]TeamScore, error) {
+	strategy, err := StrategyFor(game.Scoring)
+	if err != nil {
+		return nil, err
+	}
+	guesses, err := Guesses(game)
+	if err != nil {
+		return nil, err
+	}
+	owners := OwnersOf(game)
+
+	byTeam := make(map[string]*TeamScore)
+	for _, t := range game.Teams {
+		byTeam[TeamKey(t.ID)] = &TeamScore{TeamID: t.ID, Name: t.Name, Members: []string{}}
+	}
+	for _, s := range game.Submissions {
+		if s.TeamID != nil {
+			if score, ok := byTeam[TeamKey(*s.TeamID)]; ok {
+				score.Members = append(score.Members, s.Nickname)
+			}
+		}
+	}
+
+	teamGuesses := []Guess{}
+	for _, g := range guesses {
+		g.PlayerID = owners.Side(g.PlayerID)
+		teamGuesses = append(teamGuesses, g)
+		if score, ok := byTeam[g.PlayerID]; ok {
+			score.Total++
+			if g.Correct() {
+				score.Correct++
+			}
+		}
+	}
+	for key, points := range strategy.Points(teamGuesses) {
+		// Guessers who never joined a team don't score for one
+		if score, ok := byTeam[key]; ok {
+			score.Points = points
+		}
+	}
+
+	scores := []TeamScore{}
+	for _, score := range byTeam {
+		if score.Total > 0 {
+			score.Percent = float64(score.Correct) / float64(score.Total) * 100
+		}
+		sort.Strings(score.Members)
+		scores = append(scores, *score)
+	}
+	rankTeams(scores)
+	return scores, nil
+}
+
+// rankTeams sorts team scores, best first, and ranks them with ties sharing a rank
+func rankTeams(scores []TeamScore) {
+	sort.Slice(scores, func(i, j int) bool {
+		if scores[i].Points != scores[j].Points {
+			return scores[i].Points > scores[j].Points
+		}
+		if scores[i].Correct != scores[j].Correct {
+			return scores[i].Correct > scores[j].Correct
+		}
+		return scores[i].Name < scores[j].Name
+	})
+	for i := range scores {
+		if i > 0 && scores[i].Points == scores[i-1].Points && scores[i].Correct == scores[i-1].Correct {
+			scores[i].Rank = scores[i-1].Rank
+		} else {
+			scores[i].Rank = i + 1
+		}
+	}
+}
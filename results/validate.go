@@ -0,0 +1,132 @@
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/charliekim2/songsleuths/db"
+)
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every problem found in a submitted ranking
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	msgs := []string{}
+	for _, f := range e.Errors {
+		msgs = append(msgs, f.Field+": "+f.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) add(field, format string, args ...any) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateRanking checks a player's ranking JSON (tier id -> song ids) against
+// the game: every tier must belong to the tierlist, every song to the game,
+// and no song may be placed twice. Guess lists must also place every song
+// the player did not submit. Only songs from the tierlist's round count. The
+// game must be loaded with LoadGame.
+func ValidateRanking(game *db.Game, playerID string, tierlistID uint, raw string) error {
+	verr := &ValidationError{}
+
+	var list *db.Tierlist
+	for i := range game.Tierlists {
+		if game.Tierlists[i].ID == tierlistID {
+			list = &game.Tierlists[i]
+		}
+	}
+	if list == nil {
+		verr.add("tierlist_id", "tierlist %d does not belong to this game", tierlistID)
+		return verr
+	}
+	if game.NRounds > 1 {
+		game = Round(game, list.Round)
+	}
+
+	var placement map[string][]string
+	if err := json.Unmarshal([]byte(raw), &placement); err != nil {
+		verr.add("ranking", "must be a JSON object mapping tier ids to lists of song ids")
+		return verr
+	}
+
+	tiers := make(map[uint]bool)
+	for _, tier := range list.Tiers {
+		tiers[tier.ID] = true
+	}
+	songs := make(map[uint]string) // Song id -> submitter
+	for _, s := range game.Submissions {
+		for _, song := range s.Songs {
+			songs[song.ID] = s.PlayerID
+		}
+	}
+
+	// Walk tiers in a fixed order so duplicate errors are reported consistently
+	keys := make([]string, 0, len(placement))
+	for key := range placement {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	placed := make(map[uint]string) // Song id -> tier key it was first placed in
+	for _, key := range keys {
+		field := "ranking." + key
+		tid, err := strconv.ParseUint(key, 10, 0)
+		if err != nil {
+			verr.add(field, "tier id must be a number")
+			continue
+		}
+		if !tiers[uint(tid)] {
+			verr.add(field, "tier %d does not belong to tierlist %d", tid, list.ID)
+			continue
+		}
+		for i, songID := range placement[key] {
+			songField := fmt.Sprintf("%s[%d]", field, i)
+			sid, err := strconv.ParseUint(songID, 10, 0)
+			if err != nil {
+				verr.add(songField, "song id must be a number")
+				continue
+			}
+			if _, ok := songs[uint(sid)]; !ok {
+				verr.add(songField, "song %d is not in this game", sid)
+				continue
+			}
+			if prev, ok := placed[uint(sid)]; ok {
+				verr.add(songField, "song %d is already placed in tier %s", sid, prev)
+				continue
+			}
+			placed[uint(sid)] = key
+		}
+	}
+
+	if list.Type == "guess" {
+		// Songs from the player's own team don't need guessing either
+		owners := OwnersOf(game)
+		side := owners.Side(playerID)
+		missing := []uint{}
+		for sid := range songs {
+			if _, ok := placed[sid]; !ok && owners.Songs[sid] != side {
+				missing = append(missing, sid)
+			}
+		}
+		sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+		for _, sid := range missing {
+			verr.add("ranking", "song %d must be placed", sid)
+		}
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charliekim2/songsleuths/db"
+	"github.com/charliekim2/songsleuths/utils"
+)
+
+// Kind is one type of machine-checked rule a game can put on its songs
+type Kind struct {
+	// Checks the value the host gave when creating the game
+	Validate func(value string) error
+	// Explains why the track breaks the rule, or returns "" if it doesn't
+	Broken func(t utils.Track, value string) string
+}
+
+// Kinds are the built-in rules, keyed by the kind stored on db.Rule
+var Kinds = map[string]Kind{
+	"released_before": {
+		Validate: validateYear,
+		Broken: func(t utils.Track, value string) string {
+			year, _ := strconv.Atoi(value)
+			if t.ReleaseYear == 0 || t.ReleaseYear < year {
+				return ""
+			}
+			return fmt.Sprintf("was released in %d, songs must be from before %d", t.ReleaseYear, year)
+		},
+	},
+	"released_since": {
+		Validate: validateYear,
+		Broken: func(t utils.Track, value string) string {
+			year, _ := strconv.Atoi(value)
+			if t.ReleaseYear == 0 || t.ReleaseYear >= year {
+				return ""
+			}
+			return fmt.Sprintf("was released in %d, songs must be from %d or later", t.ReleaseYear, year)
+		},
+	},
+	"no_explicit": {
+		Validate: func(value string) error { return nil },
+		Broken: func(t utils.Track, value string) string {
+			if !t.Explicit {
+				return ""
+			}
+			return "is explicit, explicit songs are not allowed"
+		},
+	},
+	"max_duration": {
+		Validate: validateSeconds,
+		Broken: func(t utils.Track, value string) string {
+			secs, _ := strconv.Atoi(value)
+			if t.DurationMs <= secs*1000 {
+				return ""
+			}
+			return fmt.Sprintf("is %s long, songs must be at most %s", duration(t.DurationMs/1000), duration(secs))
+		},
+	},
+	"min_duration": {
+		Validate: validateSeconds,
+		Broken: func(t utils.Track, value string) string {
+			secs, _ := strconv.Atoi(value)
+			if t.DurationMs >= secs*1000 {
+				return ""
+			}
+			return fmt.Sprintf("is %s long, songs must be at least %s", duration(t.DurationMs/1000), duration(secs))
+		},
+	},
+	"artist": {
+		Validate: validateArtist,
+		Broken: func(t utils.Track, value string) string {
+			if hasArtist(t, value) {
+				return ""
+			}
+			return "is not by " + value
+		},
+	},
+	"not_artist": {
+		Validate: validateArtist,
+		Broken: func(t utils.Track, value string) string {
+			if !hasArtist(t, value) {
+				return ""
+			}
+			return "is by " + value + ", who is not allowed"
+		},
+	},
+}
+
+func Validate(rules []db.Rule) error {
+	if len(rules) > 10 {
+		return errors.New("a game can have at most 10 rules")
+	}
+	for _, r := range rules {
+		kind, ok := Kinds[r.Kind]
+		if !ok {
+			return errors.New("unknown rule " + r.Kind)
+		}
+		if err := kind.Validate(r.Value); err != nil {
+			return fmt.Errorf("%s: %w", r.Kind, err)
+		}
+	}
+	return nil
+}
+
+// Check returns an error naming every track that breaks one of the rules and why
+func Check(rules []db.Rule, tracks []utils.Track) error {
+	reasons := []string{}
+	for _, t := range tracks {
+		for _, r := range rules {
+			kind, ok := Kinds[r.Kind]
+			if !ok {
+				continue
+			}
+			if reason := kind.Broken(t, r.Value); reason != "" {
+				reasons = append(reasons, fmt.Sprintf("%q %s", t.Name, reason))
+			}
+		}
+	}
+	if len(reasons) > 0 {
+		return errors.New(strings.Join(reasons, "; "))
+	}
+	return nil
+}
+
+func validateYear(value string) error {
+	year, err := strconv.Atoi(value)
+	if err != nil || year < 1900 || year > 2100 {
+		return errors.New("value must be a year")
+	}
+	return nil
+}
+
+func validateSeconds(value string) error {
+	secs, err := strconv.Atoi(value)
+	if err != nil || secs < 1 {
+		return errors.New("value must be a number of seconds")
+	}
+	return nil
+}
+
+func validateArtist(value string) error {
+	if len(value) < 1 || len(value) > 100 {
+		return errors.New("value must be an artist name")
+	}
+	return nil
+}
+
+func hasArtist(t utils.Track, name string) bool {
+	for _, a := range t.Artists {
+		if strings.EqualFold(a, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Formats seconds as m:ss
+func duration(secs int) string {
+	return fmt.Sprintf("%d:%02d", secs/60, secs%60)
+}
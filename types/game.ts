@@ -2,7 +2,10 @@ interface Game {
   id: string;
   name: string;
   deadline: number;
+  ranking_deadline?: number;
   n_songs: number;
+  phase: string;
+  ranking_mode: string;
 
   submission?: Submission;
   // player_list?: Submission[];
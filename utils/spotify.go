@@ -134,6 +134,57 @@ func Search(query string, limit int) (*SearchResult, error) {
 	return &result, nil
 }
 
+type playlistRequest struct {
+	Name        string `json:"name"`
+	Public      bool   `json:"public"`
+	Description string `json:"description"`
+}
+
+type playlistResponse struct {
+	ID string `json:"id"`
+}
+
+// CreatePlaylist makes a private playlist for a game and returns its id
+func CreatePlaylist(name string) (string, error) {
+	tok, err := RefreshToken()
+	if err != nil {
+		return "", err
+	}
+
+	playlist := playlistRequest{
+		Name:        name,
+		Description: "Song Sleuths playlist for " + name,
+		Public:      false,
+	}
+	body, err := json.Marshal(&playlist)
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequest("POST", "https://api.spotify.com/v1/users/charliekim451/playlists", bytes.NewBuffer(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("Content-Type", "application/json")
+	req.Header.Add("Authorization", "Bearer "+tok)
+
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		return "", errors.New("Spotify rejected playlist creation request")
+	}
+	playlistId := playlistResponse{}
+	err = json.NewDecoder(res.Body).Decode(&playlistId)
+	if err != nil {
+		return "", err
+	}
+	return playlistId.ID, nil
+}
+
 type uris struct {
 	URIs []string `json:"uris"`
 }
@@ -180,9 +231,15 @@ type TrackResult struct {
 			Images []struct {
 				URL string `json:"url"`
 			} `json:"images"`
+			ReleaseDate string `json:"release_date"` // YYYY, YYYY-MM or YYYY-MM-DD
 		} `json:"album"`
-		ID   string `json:"id"`
-		Name string `json:"name"`
+		Artists []struct {
+			Name string `json:"name"`
+		} `json:"artists"`
+		ID         string `json:"id"`
+		Name       string `json:"name"`
+		Explicit   bool   `json:"explicit"`
+		DurationMs int    `json:"duration_ms"`
 	} `json:"tracks"`
 }
 
@@ -192,7 +249,16 @@ type AlbumArt struct {
 	Name string // show song name on hover
 }
 
-func GetAlbumArt(songs []string) ([]AlbumArt, error) {
+// Track is a song's cover art plus the metadata game rules are checked against
+type Track struct {
+	AlbumArt
+	Artists     []string
+	ReleaseYear int // 0 if Spotify doesn't know
+	Explicit    bool
+	DurationMs  int
+}
+
+func GetTracks(songs []string) ([]Track, error) {
 	token, err := appToken()
 	if err != nil {
 		return nil, err
@@ -223,21 +289,65 @@ func GetAlbumArt(songs []string) ([]AlbumArt, error) {
 		return nil, err
 	}
 
-	covers := []AlbumArt{}
+	tracks := []Track{}
 	for _, t := range result.Tracks {
+		track := Track{
+			AlbumArt:   AlbumArt{ID: t.ID, Name: t.Name},
+			Explicit:   t.Explicit,
+			DurationMs: t.DurationMs,
+		}
 		if len(t.Album.Images) > 0 {
-			covers = append(covers, AlbumArt{
-				ID:   t.ID,
-				URL:  t.Album.Images[0].URL,
-				Name: t.Name,
-			})
-		} else {
-			covers = append(covers, AlbumArt{
-				ID:  t.ID,
-				URL: "",
-			})
+			track.URL = t.Album.Images[0].URL
+		}
+		for _, a := range t.Artists {
+			track.Artists = append(track.Artists, a.Name)
 		}
+		if len(t.Album.ReleaseDate) >= 4 {
+			track.ReleaseYear, _ = strconv.Atoi(t.Album.ReleaseDate[:4])
+		}
+		tracks = append(tracks, track)
+	}
+
+	return tracks, nil
+}
+
+func GetAlbumArt(songs []string) ([]AlbumArt, error) {
+	tracks, err := GetTracks(songs)
+	if err != nil {
+		return nil, err
+	}
+
+	covers := []AlbumArt{}
+	for _, t := range tracks {
+		covers = append(covers, t.AlbumArt)
 	}
 
 	return covers, nil
 }
+
+// DeletePlaylist removes a game's playlist. Spotify has no true delete, so
+// the account unfollows it instead.
+func DeletePlaylist(playlist string) error {
+	tok, err := RefreshToken()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("DELETE", "https://api.spotify.com/v1/playlists/"+playlist+"/followers", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Authorization", "Bearer "+tok)
+
+	client := &http.Client{}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.New("Spotify rejected playlist deletion request")
+	}
+	return nil
+}